@@ -0,0 +1,36 @@
+// Package caller provides information of callers, which mockrt and mockrt3
+// record with calls.
+package caller
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// Site returns "file:line" of a caller.  The argument skip is the number of
+// stack frames to ascend, with 0 identifying the caller of Site.
+func Site(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "???:0"
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+}
+
+// Goroutine returns ID of the current goroutine.
+func Goroutine() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if n := bytes.IndexByte(b, ' '); n >= 0 {
+		b = b[:n]
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
@@ -11,6 +11,10 @@ import (
 
 var ForTest bool = false
 
+var Wrap bool = false
+
+var Counter bool = false
+
 type Variable struct {
 	Name string
 	Typ  string
@@ -40,12 +44,63 @@ func (vv Vars) NamesPrefix(prefix string) string {
 	})
 }
 
+// CallArgs returns names of variables as arguments to call a function.
+// A variadic variable is spread with "...".
+func (vv Vars) CallArgs() string {
+	return vv.Join(func(v *Variable) string {
+		if strings.HasPrefix(v.Typ, "...") {
+			return v.Name + "..."
+		}
+		return v.Name
+	})
+}
+
 func (vv Vars) Types() string {
 	return vv.Join(func(v *Variable) string {
 		return v.Typ
 	})
 }
 
+// basicTypes is a set of predeclared types, which can be compared with ==
+// like cmp.Equal does.
+var basicTypes = map[string]bool{
+	"bool": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"byte": true, "rune": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// IsBasic checks the variable is of a basic type, which can be compared with
+// ==.
+func (v *Variable) IsBasic() bool {
+	return basicTypes[v.Typ]
+}
+
+// IsBasicSlice checks the variable is a slice (or variadic) of a basic type.
+func (v *Variable) IsBasicSlice() bool {
+	typ := ToStructFieldType(v.Typ)
+	return strings.HasPrefix(typ, "[]") && basicTypes[typ[2:]]
+}
+
+// IsFunc checks the variable is of a func type (including variadic one),
+// which cmp can't compare unless it is nil.
+func (v *Variable) IsFunc() bool {
+	return strings.HasPrefix(strings.TrimPrefix(v.Typ, "..."), "func(")
+}
+
+// Funcs returns variables of func types (including variadic ones), which cmp
+// can't compare unless they are nil.
+func (vv Vars) Funcs() Vars {
+	var r Vars
+	for _, v := range vv {
+		if v.IsFunc() {
+			r.add(v)
+		}
+	}
+	return r
+}
+
 func (vv Vars) Join(fn func(v *Variable) string) string {
 	b := &strings.Builder{}
 	for i, v := range vv {
@@ -72,6 +127,14 @@ func (m *Method) ReturnTypeName() string {
 	return m.Typn + m.Name + "_R"
 }
 
+func (m *Method) ExpectTypeName() string {
+	return m.Typn + m.Name + "_E"
+}
+
+func (m *Method) HandleName() string {
+	return m.Typn + m.Name + "_M"
+}
+
 // varName generates variable name.
 func varName(name string, attr string, n int) string {
 	if name != "" {
@@ -0,0 +1,78 @@
+// Package comparer provides registries of comparers by type, which are used
+// as options of cmp.  The process-wide registry is shared by mockrt and
+// mockrt3, so comparers which were registered once are used by both runtimes.
+package comparer
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Registry is a registry of comparers by type.
+type Registry map[reflect.Type]cmp.Option
+
+// Add adds a comparer fn, which must be a func(T, T) bool, to the registry.
+// A comparer which was added for same type is replaced.
+func (r *Registry) Add(fn interface{}) error {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.In(0) != ft.In(1) || ft.NumOut() != 1 || ft.Out(0).Kind() != reflect.Bool {
+		return fmt.Errorf("comparer should be func(T, T) bool, got %T", fn)
+	}
+	if *r == nil {
+		*r = Registry{}
+	}
+	(*r)[ft.In(0)] = cmp.Comparer(fn)
+	return nil
+}
+
+var (
+	mu     sync.RWMutex
+	global Registry
+)
+
+// Register adds a comparer fn to the process-wide registry.
+func Register(fn interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return global.Add(fn)
+}
+
+// Reset clears the process-wide registry.  This is used by tests.
+func Reset() {
+	mu.Lock()
+	global = nil
+	mu.Unlock()
+}
+
+// Registered checks any comparers are in the process-wide registry or local.
+func Registered(local Registry) bool {
+	if len(local) > 0 {
+		return true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(global) > 0
+}
+
+// Options returns comparers in the process-wide registry and local as
+// options.  Comparers in local take precedence.
+func Options(local Registry) []cmp.Option {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(global) == 0 && len(local) == 0 {
+		return nil
+	}
+	opts := make([]cmp.Option, 0, len(global)+len(local))
+	for typ, opt := range global {
+		if _, ok := local[typ]; !ok {
+			opts = append(opts, opt)
+		}
+	}
+	for _, opt := range local {
+		opts = append(opts, opt)
+	}
+	return opts
+}
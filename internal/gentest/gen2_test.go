@@ -0,0 +1,93 @@
+//go:build mock
+// +build mock
+
+package gentest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/koron/mockgo/mockrt"
+	"github.com/koron/mockgo/testdata/mock1_gen2"
+)
+
+// fakeReporter is a Reporter which records reported failures, and never
+// exits a goroutine.
+type fakeReporter struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (*fakeReporter) Helper() {}
+
+func (fr *fakeReporter) Errorf(format string, args ...interface{}) {
+	fr.errors = append(fr.errors, fmt.Sprintf(format, args...))
+}
+
+func (fr *fakeReporter) Fatalf(format string, args ...interface{}) {
+	fr.errors = append(fr.errors, fmt.Sprintf(format, args...))
+}
+
+func (fr *fakeReporter) Cleanup(fn func()) {
+	fr.cleanups = append(fr.cleanups, fn)
+}
+
+func (fr *fakeReporter) end() {
+	for i := len(fr.cleanups) - 1; i >= 0; i-- {
+		fr.cleanups[i]()
+	}
+}
+
+func TestGen2Call(t *testing.T) {
+	m := &mock1_gen2.Foo{Q: mockrt.NewSequence(t,
+		mockrt.Call{Parameter: mock1_gen2.FooHello_P{Name: "foo"}, Result: mock1_gen2.FooHello_R{}},
+	)}
+	if err := m.Hello("foo"); err != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+}
+
+func TestGen2Late(t *testing.T) {
+	var lates []mockrt.Late
+	mockrt.HandleLate(func(l mockrt.Late) { lates = append(lates, l) })
+	defer mockrt.HandleLate(nil)
+	fr := &fakeReporter{}
+	m := &mock1_gen2.Foo{Q: mockrt.NewSequence(fr)}
+	fr.end()
+	if err := m.Hello("foo"); err != nil {
+		t.Errorf("unexpected result for late call: %v", err)
+	}
+	if len(fr.errors) != 0 {
+		t.Errorf("late call should not be reported to ended test: %q", fr.errors)
+	}
+	if len(lates) != 1 || lates[0].Name != "Foo.Hello" {
+		t.Errorf("late call should be handled: %+v", lates)
+	}
+}
+
+func TestGen2NonExitingReporter(t *testing.T) {
+	fr := &fakeReporter{}
+	m := &mock1_gen2.Foo{Q: mockrt.NewSequence(fr,
+		mockrt.Call{Parameter: mock1_gen2.FooHello_P{Name: "foo"}, Result: mock1_gen2.FooHello_R{}},
+	)}
+	if err := m.Hello("bar"); err != nil {
+		t.Errorf("unexpected result for unexpected arguments: %v", err)
+	}
+	if err := m.Hello("foo"); err != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+	if err := m.Hello("baz"); err != nil {
+		t.Errorf("unexpected result for extra call: %v", err)
+	}
+	fr.end()
+	if len(fr.errors) != 2 {
+		t.Fatalf("unexpected failures: %q", fr.errors)
+	}
+	if !strings.HasPrefix(fr.errors[0], "call for Foo.Hello (#0) has unexpected arguments") {
+		t.Errorf("unexpected failure #0: %s", fr.errors[0])
+	}
+	if !strings.HasPrefix(fr.errors[1], "no calls at #1 for Foo.Hello") {
+		t.Errorf("unexpected failure #1: %s", fr.errors[1])
+	}
+}
@@ -0,0 +1,141 @@
+//go:build mock
+// +build mock
+
+package gentest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/koron/mockgo/mockrt3"
+	"github.com/koron/mockgo/testdata/mock1_gen3"
+	"github.com/koron/mockgo/testdata/mock2_gen3wrap"
+)
+
+func TestGen3Expect(t *testing.T) {
+	errFoo := errors.New("foo")
+	m := &mock1_gen3.Foo{Q: mockrt3.NewQ(t)}
+	m.ExpectHello("foo").Return(errFoo)
+	m.ExpectHello("bar").Return(nil)
+	if err := m.Hello("foo"); err != errFoo {
+		t.Errorf("unexpected result for foo: %v", err)
+	}
+	if err := m.Hello("bar"); err != nil {
+		t.Errorf("unexpected result for bar: %v", err)
+	}
+}
+
+var rxSite = regexp.MustCompile(`\(#0, (\S+)\)`)
+
+func TestGen3ExpectSite(t *testing.T) {
+	fr := &fakeReporter{}
+	m := &mock1_gen3.Foo{Q: mockrt3.NewQ(fr)}
+	_, _, line, _ := runtime.Caller(0)
+	m.ExpectHello("foo").Return(nil)
+	m.Hello("bar")
+	if len(fr.errors) != 1 {
+		t.Fatalf("unexpected failures: %q", fr.errors)
+	}
+	want := fmt.Sprintf("gen3_test.go:%d", line+1)
+	if m := rxSite.FindStringSubmatch(fr.errors[0]); m == nil || m[1] != want {
+		t.Errorf("expectation should be located at %s: %s", want, fr.errors[0])
+	}
+}
+
+func TestGen3Handle(t *testing.T) {
+	errFoo := errors.New("foo")
+	q := mockrt3.NewQ(t, mock1_gen3.FooHello_M.C(mock1_gen3.FooHello_P{Name: "foo"}, mock1_gen3.FooHello_R{Out0: errFoo}))
+	mock1_gen3.FooHello_M.Expect(q, mock1_gen3.FooHello_P{Name: "bar"}, mock1_gen3.FooHello_R{})
+	m := &mock1_gen3.Foo{Q: q}
+	if err := m.Hello("foo"); err != errFoo {
+		t.Errorf("unexpected result for foo: %v", err)
+	}
+	if err := m.Hello("bar"); err != nil {
+		t.Errorf("unexpected result for bar: %v", err)
+	}
+	want := []mockrt3.P{mock1_gen3.FooHello_P{Name: "foo"}, mock1_gen3.FooHello_P{Name: "bar"}}
+	if d := cmp.Diff(want, q.Params(mock1_gen3.FooHello_M.Name())); d != "" {
+		t.Errorf("unexpected parameters: -want +got\n%s", d)
+	}
+}
+
+// realBar is a real implementation of pkg2.Bar, which records calls.
+type realBar struct {
+	calls []string
+}
+
+func (r *realBar) Get(key string) (string, error) {
+	r.calls = append(r.calls, "Get "+key)
+	return "real " + key, nil
+}
+
+func (r *realBar) Put(ctx context.Context, key string, vals ...int) {
+	r.calls = append(r.calls, fmt.Sprintf("Put %s %v", key, vals))
+}
+
+func (r *realBar) Close() {
+	r.calls = append(r.calls, "Close")
+}
+
+func (r *realBar) Watch(key string, fn func(string)) error {
+	r.calls = append(r.calls, "Watch "+key)
+	fn(key)
+	return nil
+}
+
+func TestGen3WrapSpy(t *testing.T) {
+	rb := &realBar{}
+	m := &mock2_gen3wrap.Bar{Q: mockrt3.NewQ(t).WithOption(mockrt3.IgnoreContext), Real: rb}
+	m.ExpectGet("foo").Return("stub foo", nil)
+	m.ExpectPut(context.Background(), "foo", 1, 2).Return()
+	if v, err := m.Get("bar"); v != "real bar" || err != nil {
+		t.Errorf("unexpected result for bar: %q %v", v, err)
+	}
+	if v, err := m.Get("foo"); v != "stub foo" || err != nil {
+		t.Errorf("unexpected result for foo: %q %v", v, err)
+	}
+	m.Put(context.Background(), "foo", 1, 2)
+	m.Put(context.Background(), "bar", 3)
+	var watched []string
+	if err := m.Watch("baz", func(s string) { watched = append(watched, s) }); err != nil {
+		t.Errorf("unexpected result for watch: %v", err)
+	}
+	m.Close()
+	if d := cmp.Diff([]string{"Get bar", "Put bar [3]", "Watch baz", "Close"}, rb.calls); d != "" {
+		t.Errorf("unexpected real calls: -want +got\n%s", d)
+	}
+	if d := cmp.Diff([]string{"baz"}, watched); d != "" {
+		t.Errorf("unexpected watched: -want +got\n%s", d)
+	}
+}
+
+func TestGen3WrapStrict(t *testing.T) {
+	rb := &realBar{}
+	m := &mock2_gen3wrap.Bar{Q: mockrt3.NewQ(t).Strict(), Real: rb}
+	m.ExpectWatch("foo", nil).Return(nil)
+	// fn is ignored on comparison.
+	if err := m.Watch("foo", func(string) {}); err != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+	if len(rb.calls) != 0 {
+		t.Errorf("strict mock should not forward calls: %q", rb.calls)
+	}
+}
+
+func TestGen3WrapNoReal(t *testing.T) {
+	fr := &fakeReporter{}
+	m := &mock2_gen3wrap.Bar{Q: mockrt3.NewQ(fr)}
+	m.ExpectGet("foo").Return("stub foo", nil)
+	if v, err := m.Get("bar"); v != "" || err != nil {
+		t.Errorf("unexpected result for bar: %q %v", v, err)
+	}
+	if len(fr.errors) != 1 || !strings.Contains(fr.errors[0], "Real of the mock is nil") {
+		t.Errorf("unexpected failures: %q", fr.errors)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build mock
+// +build mock
+
+package gentest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/koron/mockgo/testdata/mock2_gen4counter"
+)
+
+func TestGen4Counter(t *testing.T) {
+	m := &mock2_gen4counter.Bar{
+		GetFunc:   func(key string) (string, error) { return key, nil },
+		CloseFunc: func() {},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Get("foo")
+			}
+			m.Close()
+		}()
+	}
+	wg.Wait()
+	if got := m.GetCalls.Load(); got != 1000 {
+		t.Errorf("unexpected GetCalls: want=1000 got=%d", got)
+	}
+	if got := m.CloseCalls.Load(); got != 10 {
+		t.Errorf("unexpected CloseCalls: want=10 got=%d", got)
+	}
+	if got := m.PutCalls.Load(); got != 0 {
+		t.Errorf("unexpected PutCalls: want=0 got=%d", got)
+	}
+}
@@ -0,0 +1,6 @@
+// Package gentest tests mocks in testdata by calling them, in addition to
+// comparing them with generated ones.  Tests are built with the mock tag like
+// generated mocks, run them with:
+//
+//	go test -tags mock ./internal/gentest
+package gentest
@@ -0,0 +1,107 @@
+// Package late provides a process-wide registry of mock calls which were made
+// after the end of tests.  This is shared by mockrt and mockrt3, so one
+// handler receives late calls of both runtimes.
+package late
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/koron/mockgo/internal/caller"
+)
+
+// Call is a call which was made after the end of the test, typically by a
+// goroutine which outlives the test.
+type Call struct {
+	// Test is name of the test which the mock belongs to, when the reporter
+	// has Name method like testing.TB.
+	Test string
+
+	// Name is name of the called method, like "Foo.Hello".
+	Name string
+
+	// Parameter is parameters of the call.
+	Parameter interface{}
+
+	// Goroutine is ID of a goroutine which made the call.
+	Goroutine int64
+
+	// Stack is a stack trace of the goroutine which made the call.
+	Stack string
+}
+
+// String describes the late call with the stack trace.
+func (c Call) String() string {
+	return fmt.Sprintf("%s %+v was called after the end of test %s on goroutine %d:\n%s", c.Name, c.Parameter, c.Test, c.Goroutine, c.Stack)
+}
+
+// testNamer is implemented by testing.TB.
+type testNamer interface {
+	Name() string
+}
+
+// New creates a late call of a mock, which is made on the current goroutine.
+// t is a reporter of the mock.
+func New(t interface{}, name string, param interface{}) Call {
+	c := Call{
+		Name:      name,
+		Parameter: param,
+		Goroutine: caller.Goroutine(),
+		Stack:     string(debug.Stack()),
+	}
+	if n, ok := t.(testNamer); ok {
+		c.Test = n.Name()
+	}
+	return c
+}
+
+var (
+	mu      sync.Mutex
+	handler func(Call)
+	queue   []Call
+)
+
+// Handle sets a process-wide handler for late calls.  When fn is nil, late
+// calls are queued until Drain.
+func Handle(fn func(Call)) {
+	mu.Lock()
+	handler = fn
+	mu.Unlock()
+}
+
+// Add passes a late call to the handler, or queues it.
+func Add(c Call) {
+	mu.Lock()
+	fn := handler
+	if fn == nil {
+		queue = append(queue, c)
+	}
+	mu.Unlock()
+	if fn != nil {
+		fn(c)
+	}
+}
+
+// Drain returns queued late calls, and clears the queue.
+func Drain() []Call {
+	mu.Lock()
+	defer mu.Unlock()
+	calls := queue
+	queue = nil
+	return calls
+}
+
+// reporter is implemented by Reporter of mockrt and mockrt3.
+type reporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Report reports queued late calls, which were made by other tests, to t.
+func Report(t reporter) {
+	t.Helper()
+	for _, c := range Drain() {
+		t.Errorf("leaked goroutine: %s", c)
+	}
+}
@@ -43,6 +43,8 @@ func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type,
 			fmt.Fprintf(w, "\t%s %s\n", common.ToPub(a.Name), typ)
 		}
 		fmt.Fprintf(w, "}\n\n")
+		fmt.Fprintf(w, "// M__ tells mockrt the name of the method\n")
+		fmt.Fprintf(w, "func (%s) M__() string { return %q }\n\n", m.ParamTypeName(), mockTypn+"."+m.Name)
 
 		// write result type for the method.
 		fmt.Fprintf(w, "// %s packs output parameters of %s#%s method.\n", m.ReturnTypeName(), origTypn, m.Name)
@@ -56,7 +58,7 @@ func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type,
 		fmt.Fprintf(w, "// %s is mock of %s#%[1]s method.\n", m.Name, origTypn)
 		fmt.Fprintf(w, "func (_m *%s) %s(%s) (%s) {\n", mockTypn, m.Name, m.Args.NameTypes(), m.Rets.Types())
 		fmt.Fprintf(w, "\t_m.Q.T().Helper()\n")
-		fmt.Fprintf(w, "\t_r := (_m.Q.Call(%q, %s{%s})).(%s)\n", mockTypn+"."+m.Name, m.ParamTypeName(), m.Args.Names(), m.ReturnTypeName())
+		fmt.Fprintf(w, "\t_r, _ := (_m.Q.Call(%q, %s{%s})).(%s)\n", mockTypn+"."+m.Name, m.ParamTypeName(), m.Args.Names(), m.ReturnTypeName())
 		fmt.Fprintf(w, "\treturn %s\n", m.Rets.NamesPrefix("_r"))
 		fmt.Fprintf(w, "}\n")
 	}
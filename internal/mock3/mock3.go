@@ -4,6 +4,7 @@ package mock3
 import (
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/koron-go/srcdom"
 	"github.com/koron/mockgo/internal/common"
@@ -30,6 +31,27 @@ func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type,
 	fmt.Fprintf(w, "// %s is a mock of %s for test.\n", mockTypn, origTypn)
 	fmt.Fprintf(w, "type %s struct {\n", mockTypn)
 	fmt.Fprintf(w, "\tQ *mockrt3.Q\n")
+	if common.Wrap {
+		fmt.Fprintf(w, "\tReal %s_Real\n", mockTypn)
+	}
+	fmt.Fprintf(w, "}\n")
+
+	// write the interface for the real implementation.
+	if common.Wrap {
+		fmt.Fprintf(w, "\n// %s_Real is an interface of %s, which %s forwards calls to.\n", mockTypn, origTypn, mockTypn)
+		fmt.Fprintf(w, "type %s_Real interface {\n", mockTypn)
+		for _, m := range methods {
+			fmt.Fprintf(w, "\t%s(%s) (%s)\n", m.Name, m.Args.NameTypes(), m.Rets.Types())
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+
+	// write the type registry for the mock type.
+	fmt.Fprintf(w, "\n// %s_Types is a registry of P and R types of %[1]s for mockrt3.Load.\n", mockTypn)
+	fmt.Fprintf(w, "var %s_Types = mockrt3.Types{\n", mockTypn)
+	for _, m := range methods {
+		fmt.Fprintf(w, "\t%q: {P: %s{}, R: %s{}},\n", mockTypn+"."+m.Name, m.ParamTypeName(), m.ReturnTypeName())
+	}
 	fmt.Fprintf(w, "}\n")
 
 	for _, m := range methods {
@@ -45,6 +67,31 @@ func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type,
 		fmt.Fprintf(w, "}\n\n")
 		fmt.Fprintf(w, "// P__ implements mockrt3.P interface\n")
 		fmt.Fprintf(w, "func (%s) P__() {}\n\n", m.ParamTypeName())
+		fmt.Fprintf(w, "// M__ tells mockrt3 the name of the method\n")
+		fmt.Fprintf(w, "func (%s) M__() string { return %q }\n\n", m.ParamTypeName(), mockTypn+"."+m.Name)
+		if ig := m.Args.Funcs(); len(ig) > 0 {
+			fmt.Fprintf(w, "// Ignored__ tells mockrt3 fields of func types, which are ignored on\n")
+			fmt.Fprintf(w, "// comparison because funcs can't be compared\n")
+			fmt.Fprintf(w, "func (%s) Ignored__() []string {\n", m.ParamTypeName())
+			fmt.Fprintf(w, "\treturn []string{%s}\n", ig.Join(func(v *common.Variable) string {
+				return strconv.Quote(common.ToPub(v.Name))
+			}))
+			fmt.Fprintf(w, "}\n\n")
+		}
+		fmt.Fprintf(w, "// Eq__ compares parameters with == where it is possible, for mockrt3\n")
+		fmt.Fprintf(w, "func (_p %s) Eq__(_x mockrt3.P) bool {\n", m.ParamTypeName())
+		if eqs := equalities(m.Args); len(eqs) == 0 {
+			fmt.Fprintf(w, "\t_, ok := _x.(%s)\n", m.ParamTypeName())
+			fmt.Fprintf(w, "\treturn ok\n")
+		} else {
+			fmt.Fprintf(w, "\t_o, ok := _x.(%s)\n", m.ParamTypeName())
+			fmt.Fprintf(w, "\treturn ok")
+			for _, eq := range eqs {
+				fmt.Fprintf(w, " && %s", eq)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, "}\n\n")
 
 		// write result type for the method.
 		fmt.Fprintf(w, "// %s packs output parameters of %s#%s method.\n", m.ReturnTypeName(), origTypn, m.Name)
@@ -55,14 +102,80 @@ func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type,
 		fmt.Fprintf(w, "}\n\n")
 		fmt.Fprintf(w, "// R__ implements mockrt3.R interface\n")
 		fmt.Fprintf(w, "func (%s) R__() {}\n\n", m.ReturnTypeName())
+		fmt.Fprintf(w, "// M__ tells mockrt3 the name of the method\n")
+		fmt.Fprintf(w, "func (%s) M__() string { return %q }\n\n", m.ReturnTypeName(), mockTypn+"."+m.Name)
+
+		// write typed handle for the method.
+		fmt.Fprintf(w, "// %s is a typed handle of %s#%s method.\n", m.HandleName(), origTypn, m.Name)
+		fmt.Fprintf(w, "var %s = mockrt3.M[%s, %s](%q)\n\n", m.HandleName(), m.ParamTypeName(), m.ReturnTypeName(), mockTypn+"."+m.Name)
+
+		// write expectation builder for the method.
+		fmt.Fprintf(w, "// %s builds an expectation of %s#%s method.\n", m.ExpectTypeName(), origTypn, m.Name)
+		fmt.Fprintf(w, "type %s struct {\n", m.ExpectTypeName())
+		fmt.Fprintf(w, "\tq *mockrt3.Q\n")
+		fmt.Fprintf(w, "\tp %s\n", m.ParamTypeName())
+		fmt.Fprintf(w, "}\n\n")
+		fmt.Fprintf(w, "// Expect%s starts to build an expectation of %s#%[1]s method.\n", m.Name, origTypn)
+		fmt.Fprintf(w, "// Call Return to add it to Q.\n")
+		fmt.Fprintf(w, "func (_m *%s) Expect%s(%s) *%s {\n", mockTypn, m.Name, m.Args.NameTypes(), m.ExpectTypeName())
+		fmt.Fprintf(w, "\treturn &%s{q: _m.Q, p: %s{%s}}\n", m.ExpectTypeName(), m.ParamTypeName(), m.Args.Names())
+		fmt.Fprintf(w, "}\n\n")
+		fmt.Fprintf(w, "// Return adds the expectation with results to Q.\n")
+		fmt.Fprintf(w, "func (_e *%s) Return(%s) {\n", m.ExpectTypeName(), m.Rets.NameTypes())
+		fmt.Fprintf(w, "\t_e.q.T().Helper()\n")
+		fmt.Fprintf(w, "\t_e.q.AddCallDepth(1, %s.C(_e.p, %s{%s}))\n", m.HandleName(), m.ReturnTypeName(), m.Rets.Names())
+		fmt.Fprintf(w, "}\n\n")
 
 		// write mock func for the method.
 		fmt.Fprintf(w, "// %s is mock of %s#%[1]s method.\n", m.Name, origTypn)
 		fmt.Fprintf(w, "func (_m *%s) %s(%s) (%s) {\n", mockTypn, m.Name, m.Args.NameTypes(), m.Rets.Types())
 		fmt.Fprintf(w, "\t_m.Q.T().Helper()\n")
-		fmt.Fprintf(w, "\t_r := (_m.Q.Call(%q, %s{%s})).(%s)\n", mockTypn+"."+m.Name, m.ParamTypeName(), m.Args.Names(), m.ReturnTypeName())
-		fmt.Fprintf(w, "\treturn %s\n", m.Rets.NamesPrefix("_r"))
+		// a method without results doesn't need to receive R.
+		recv := "_r := "
+		if len(m.Rets) == 0 {
+			recv = ""
+		}
+		if common.Wrap {
+			// nil _fn tells mockrt3 that Real is not set.
+			fmt.Fprintf(w, "\tvar _fn func() %s\n", m.ReturnTypeName())
+			fmt.Fprintf(w, "\tif _m.Real != nil {\n")
+			fmt.Fprintf(w, "\t\t_fn = func() (_rr %s) {\n", m.ReturnTypeName())
+			if len(m.Rets) > 0 {
+				fmt.Fprintf(w, "\t\t\t%s = _m.Real.%s(%s)\n", m.Rets.NamesPrefix("_rr"), m.Name, m.Args.CallArgs())
+			} else {
+				fmt.Fprintf(w, "\t\t\t_m.Real.%s(%s)\n", m.Name, m.Args.CallArgs())
+			}
+			fmt.Fprintf(w, "\t\t\treturn _rr\n")
+			fmt.Fprintf(w, "\t\t}\n")
+			fmt.Fprintf(w, "\t}\n")
+			fmt.Fprintf(w, "\t%s%s.Forward(_m.Q, %s{%s}, _fn)\n", recv, m.HandleName(), m.ParamTypeName(), m.Args.Names())
+		} else {
+			fmt.Fprintf(w, "\t%s%s.Call(_m.Q, %s{%s})\n", recv, m.HandleName(), m.ParamTypeName(), m.Args.Names())
+		}
+		if len(m.Rets) > 0 {
+			fmt.Fprintf(w, "\treturn %s\n", m.Rets.NamesPrefix("_r"))
+		}
 		fmt.Fprintf(w, "}\n")
 	}
 	return nil
 }
+
+// equalities returns expressions to compare fields of _p and _o in Eq__.
+// Fields which can be compared with == come first, and fields of func types
+// are ignored like Ignored__.
+func equalities(args common.Vars) []string {
+	var basics, slices, others []string
+	for _, a := range args {
+		name := common.ToPub(a.Name)
+		switch {
+		case a.IsFunc():
+		case a.IsBasic():
+			basics = append(basics, fmt.Sprintf("_p.%[1]s == _o.%[1]s", name))
+		case a.IsBasicSlice():
+			slices = append(slices, fmt.Sprintf("mockrt3.EqualSlice(_p.%[1]s, _o.%[1]s)", name))
+		default:
+			others = append(others, fmt.Sprintf("mockrt3.Equal(_p.%[1]s, _o.%[1]s)", name))
+		}
+	}
+	return append(append(basics, slices...), others...)
+}
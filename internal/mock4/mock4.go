@@ -0,0 +1,62 @@
+// Package mock4 provides generator of mock version 4
+package mock4
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/koron-go/srcdom"
+	"github.com/koron/mockgo/internal/common"
+)
+
+// Generate generates a mock (ver.4) for a type.
+func Generate(w io.Writer, mockTag, mockTypn, mockPkgn string, typ *srcdom.Type, pkg *srcdom.Package) error {
+	origTypn := pkg.Name + "." + typ.Name
+	methods := common.FilterMethods(typ.Methods, mockTypn)
+	if len(methods) == 0 {
+		return fmt.Errorf("no methods in type:%s", typ.Name)
+	}
+
+	fmt.Fprintf(w, "// Code generated by github.com/koron/mockgo; DO NOT EDIT.\n\n")
+	// write headers.
+	if !common.ForTest {
+		fmt.Fprintf(w, "//go:build %s\n\n", mockTag)
+		fmt.Fprintf(w, "// +build %s\n\n", mockTag)
+	}
+	fmt.Fprintf(w, "package %s\n\n", mockPkgn)
+	if common.Counter {
+		fmt.Fprintf(w, "import \"sync/atomic\"\n\n")
+	}
+
+	// write the mock type.
+	fmt.Fprintf(w, "// %s is a mock of %s for test.\n", mockTypn, origTypn)
+	fmt.Fprintf(w, "type %s struct {\n", mockTypn)
+	for _, m := range methods {
+		fmt.Fprintf(w, "\t%sFunc func(%s) (%s)\n", m.Name, m.Args.Types(), m.Rets.Types())
+		if common.Counter {
+			fmt.Fprintf(w, "\t%sCalls atomic.Int64\n", m.Name)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+
+	for _, m := range methods {
+		fmt.Fprintf(w, "\n")
+
+		// write mock func for the method.
+		fmt.Fprintf(w, "// %s is mock of %s#%[1]s method.\n", m.Name, origTypn)
+		fmt.Fprintf(w, "func (_m *%s) %s(%s) (%s) {\n", mockTypn, m.Name, m.Args.NameTypes(), m.Rets.Types())
+		fmt.Fprintf(w, "\tif _m.%sFunc == nil {\n", m.Name)
+		fmt.Fprintf(w, "\t\tpanic(%q)\n", fmt.Sprintf("%s.%sFunc is nil, set it to mock %s#%s method", mockTypn, m.Name, origTypn, m.Name))
+		fmt.Fprintf(w, "\t}\n")
+		if common.Counter {
+			fmt.Fprintf(w, "\t_m.%sCalls.Add(1)\n", m.Name)
+		}
+		if len(m.Rets) > 0 {
+			fmt.Fprintf(w, "\treturn _m.%sFunc(%s)\n", m.Name, m.Args.CallArgs())
+		} else {
+			fmt.Fprintf(w, "\t_m.%sFunc(%s)\n", m.Name, m.Args.CallArgs())
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	return nil
+}
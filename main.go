@@ -18,6 +18,7 @@ import (
 	"github.com/koron/mockgo/internal/mock1"
 	"github.com/koron/mockgo/internal/mock2"
 	"github.com/koron/mockgo/internal/mock3"
+	"github.com/koron/mockgo/internal/mock4"
 	"golang.org/x/tools/imports"
 )
 
@@ -159,6 +160,8 @@ var (
 	mockSuffix bool
 	mockRev    int
 	noFormat   bool
+	wrap       bool
+	counter    bool
 	version    bool
 
 	mockTypeGen mockTypeGenerator
@@ -172,6 +175,8 @@ func determieMockTypeGenerator(mockRev int) error {
 		mockTypeGen = mock2.Generate
 	case 3:
 		mockTypeGen = mock3.Generate
+	case 4:
+		mockTypeGen = mock4.Generate
 	default:
 		return fmt.Errorf("unknow mock revision: %d", mockRev)
 	}
@@ -186,8 +191,10 @@ func gen() error {
 	)
 	flag.BoolVar(&forTest, "fortest", false, "generate mock for plain test, without +mock")
 	flag.BoolVar(&mockSuffix, "mocksuffix", false, "add `Mock` suffix to generated mock types")
-	flag.IntVar(&mockRev, "revision", 1, "mock revision (1-3)")
+	flag.IntVar(&mockRev, "revision", 1, "mock revision (1-4)")
 	flag.BoolVar(&noFormat, "noformat", false, "suppress goimports on generation mock code")
+	flag.BoolVar(&wrap, "wrap", false, "generate mock which wraps a real implementation (revision 3 only)")
+	flag.BoolVar(&counter, "counter", false, "add call counters to mock (revision 4 only)")
 	flag.StringVar(&outdir, "outdir", ".", "output directory")
 	flag.StringVar(&pkgname, "package", "", "package name")
 	flag.BoolVar(&verbose, "verbose", false, "show verbose/debug messages to stderr")
@@ -196,6 +203,8 @@ func gen() error {
 
 	typnames = flag.Args()
 	common.ForTest = forTest
+	common.Wrap = wrap
+	common.Counter = counter
 
 	if version {
 		showVersion()
@@ -212,6 +221,12 @@ func gen() error {
 	if err := determieMockTypeGenerator(mockRev); err != nil {
 		return err
 	}
+	if wrap && mockRev != 3 {
+		return errors.New("-wrap requires -revision 3")
+	}
+	if counter && mockRev != 4 {
+		return errors.New("-counter requires -revision 4")
+	}
 
 	// read source files, build srcdom.
 	path := filepath.ToSlash(pkgname)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/koron-go/srcdom"
+	"github.com/koron/mockgo/internal/common"
 )
 
 func TestMockFilename(t *testing.T) {
@@ -38,6 +39,8 @@ type GenOptions struct {
 	MockSuffix bool
 	MockRev    int
 	NoFormat   bool
+	Wrap       bool
+	Counter    bool
 
 	Outdir    string
 	Package   string
@@ -59,6 +62,10 @@ func (opts GenOptions) apply() {
 	mockSuffix = opts.MockSuffix
 	mockRev = opts.MockRev
 	noFormat = opts.NoFormat
+	wrap = opts.Wrap
+	common.Wrap = opts.Wrap
+	counter = opts.Counter
+	common.Counter = opts.Counter
 	//outdir = opts.Outdir
 	//pkgname = opts.Package
 	//verbose = opts.Verbose
@@ -162,3 +169,35 @@ func TestMockTypeGen3(t *testing.T) {
 	}
 	compareFile(t, "./testdata/mock1_gen3", outdir, "foo_mock.go")
 }
+
+func TestMockTypeGen3Wrap(t *testing.T) {
+	outdir := filepath.Join(t.TempDir(), "mock2_gen3wrap")
+	opts := newGenOptions("./testdata/pkg2", outdir, 3, "Bar")
+	opts.Wrap = true
+	err := runGen(opts)
+	if err != nil {
+		t.Error(err)
+	}
+	compareFile(t, "./testdata/mock2_gen3wrap", outdir, "bar_mock.go")
+}
+
+func TestMockTypeGen4(t *testing.T) {
+	outdir := filepath.Join(t.TempDir(), "mock1_gen4")
+	opts := newGenOptions("./testdata/pkg1", outdir, 4, "Foo")
+	err := runGen(opts)
+	if err != nil {
+		t.Error(err)
+	}
+	compareFile(t, "./testdata/mock1_gen4", outdir, "foo_mock.go")
+}
+
+func TestMockTypeGen4Counter(t *testing.T) {
+	outdir := filepath.Join(t.TempDir(), "mock2_gen4counter")
+	opts := newGenOptions("./testdata/pkg2", outdir, 4, "Bar")
+	opts.Counter = true
+	err := runGen(opts)
+	if err != nil {
+		t.Error(err)
+	}
+	compareFile(t, "./testdata/mock2_gen4counter", outdir, "bar_mock.go")
+}
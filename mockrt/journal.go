@@ -0,0 +1,85 @@
+package mockrt
+
+import (
+	"time"
+
+	"github.com/koron/mockgo/internal/caller"
+)
+
+// Record is a record of a call, which was made to Sequence.
+type Record struct {
+	// Name is name of the called method, like "Foo.Hello".
+	Name string
+
+	// Parameter is parameters of the call.
+	Parameter interface{}
+
+	// Result is results which were returned to the caller. This is nil when
+	// the call was failed.
+	Result interface{}
+
+	// Time is the time when the call was made.
+	Time time.Time
+
+	// Goroutine is ID of a goroutine which made the call.
+	Goroutine int64
+
+	// Site is "file:line" of the caller of the mock method.
+	Site string
+}
+
+// record appends a Record for a call to the journal.
+func (s *Sequence) record(name string, param interface{}, site string) *Record {
+	rec := &Record{
+		Name:      name,
+		Parameter: param,
+		Time:      time.Now(),
+		Goroutine: caller.Goroutine(),
+		Site:      site,
+	}
+	s.mu.Lock()
+	s.journal = append(s.journal, rec)
+	s.mu.Unlock()
+	return rec
+}
+
+// Journal returns records of all calls which were made to Sequence in order.
+// This is called by test codes.
+func (s *Sequence) Journal() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recs := make([]Record, len(s.journal))
+	for i, rec := range s.journal {
+		recs[i] = *rec
+	}
+	return recs
+}
+
+// Count returns number of calls which were made for a method.
+// This is called by test codes.
+func (s *Sequence) Count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for _, rec := range s.journal {
+		if rec.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+// Parameters returns parameters of calls which were made for a method in
+// order.
+// This is called by test codes.
+func (s *Sequence) Parameters(name string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var params []interface{}
+	for _, rec := range s.journal {
+		if rec.Name == name {
+			params = append(params, rec.Parameter)
+		}
+	}
+	return params
+}
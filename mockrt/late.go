@@ -0,0 +1,35 @@
+package mockrt
+
+import "github.com/koron/mockgo/internal/late"
+
+// Late is a call which was made to Sequence after the end of the test,
+// typically by a goroutine which outlives the test.  This is same type with
+// mockrt3.Late.
+type Late = late.Call
+
+// HandleLate sets a process-wide handler for calls which are made after the
+// end of the test.  fn may be called from any goroutines.  The handler is
+// shared with mockrt3.HandleLate, so it receives late calls of both mockrt and
+// mockrt3.
+//
+// By default (or when fn is nil), late calls are reported as failures of the
+// next test which calls NewSequence or mockrt3.NewQ.  Late calls never call
+// Reporter of the ended test, and return nil.
+func HandleLate(fn func(Late)) {
+	late.Handle(fn)
+}
+
+// late checks the test which Sequence belongs to has ended.  When it has
+// ended, this records the call as Late and returns true.
+func (s *Sequence) late(name string, param interface{}) bool {
+	if !s.closed.Load() {
+		return false
+	}
+	s.addLate(name, param)
+	return true
+}
+
+// addLate records a call as Late.
+func (s *Sequence) addLate(name string, param interface{}) {
+	late.Add(late.New(s.t, name, param))
+}
@@ -4,72 +4,159 @@ Package mockrt provides mock runtime for mockgo
 package mockrt
 
 import (
-	"testing"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/koron/mockgo/internal/caller"
+	"github.com/koron/mockgo/internal/comparer"
+	"github.com/koron/mockgo/internal/late"
 )
 
 // Call defines pair of request and response parameters for a method call.
 type Call struct {
 	Parameter interface{}
 	Result    interface{}
+
+	opts []cmp.Option
+}
+
+// Reporter is a reporter of failures, which Sequence uses.
+// testing.TB (*testing.T, *testing.B and *testing.F) satisfies this.
+//
+// When Reporter has Cleanup(func()) method like testing.TB, Sequence checks
+// end of calls automatically at the end of the test.
+type Reporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// cleaner is implemented by testing.TB.
+type cleaner interface {
+	Cleanup(func())
 }
 
 // Sequence is a checker of sequence of method calls
 type Sequence struct {
-	t     *testing.T
+	t Reporter
+
+	// mu guards calls, index, journal and reports of failures, against calls
+	// from goroutines.
+	mu sync.Mutex
+
 	calls []Call
 	opts  []cmp.Option
 	index int
+
+	comparers comparer.Registry
+
+	journal []*Record
+
+	skipEnd bool
+	ended   bool
+	failed  bool
+	closed  atomic.Bool
 }
 
 // NewSequence creates a sequence of calls.
 // This is called by test codes.
-func NewSequence(t *testing.T, calls ...Call) *Sequence {
-	return &Sequence{
+//
+// Sequence checks that all calls have been proceeded at the end of the test
+// automatically, when t supports Cleanup. Use SkipEndCheck to disable it.
+// Calls after the end of the test are handled as Late, see HandleLate.
+func NewSequence(t Reporter, calls ...Call) *Sequence {
+	t.Helper()
+	late.Report(t)
+	s := &Sequence{
 		t:     t,
 		calls: calls,
 	}
+	if c, ok := t.(cleaner); ok {
+		c.Cleanup(s.endCheck)
+	}
+	return s
 }
 
 // NewQ is an alias for NewSequence, creates a sequence of calls.
 // This is called by test codes.
-func NewQ(t *testing.T, calls ...Call) *Sequence {
+func NewQ(t Reporter, calls ...Call) *Sequence {
+	t.Helper()
 	return NewSequence(t, calls...)
 }
 
 // AddCall adds call data.
 // This is called by test codes.
 func (s *Sequence) AddCall(calls ...Call) *Sequence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.calls = append(s.calls, calls...)
 	return s
 }
 
-// WithOption updates compare option.
+// WithOption adds compare options.  Use Defaults for a bundle of standard
+// options.
 // This is called by test codes.
 func (s *Sequence) WithOption(opts ...cmp.Option) *Sequence {
-	s.opts = opts
+	s.opts = append(s.opts, opts...)
 	return s
 }
 
-// Call checks call parameter and returns result.
+// SkipEndCheck disables the automatic check at the end of the test, which
+// verifies all calls have been proceeded.
+// This is called by test codes.
+func (s *Sequence) SkipEndCheck() *Sequence {
+	s.skipEnd = true
+	return s
+}
+
+// Call checks call parameter and returns result.  This returns nil for late
+// calls, and on failures when Fatalf of Reporter returns.
 // This is called by mock code.
 func (s *Sequence) Call(name string, param interface{}) interface{} {
+	if s.late(name, param) {
+		return nil
+	}
 	s.t.Helper()
+	rec := s.record(name, param, caller.Site(2))
+	r := s.call(name, param)
+	s.mu.Lock()
+	rec.Result = r
+	s.mu.Unlock()
+	return r
+}
+
+// call checks call parameter and returns result.
+func (s *Sequence) call(name string, param interface{}) interface{} {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// check the end of the test again with the lock, which endCheck holds.
+	if s.closed.Load() {
+		s.addLate(name, param)
+		return nil
+	}
 	if s.index >= len(s.calls) {
+		s.failed = true
 		s.t.Fatalf("no calls at #%d for %s\nparam=%+v", s.index, name, param)
+		return nil
 	}
 	c := s.calls[s.index]
-	if d := cmp.Diff(c.Parameter, param, s.opts...); d != "" {
+	if d := cmp.Diff(c.Parameter, param, s.options(c)...); d != "" {
+		s.failed = true
 		s.t.Fatalf("call for %s (#%d) has unexpected arguments: -want +got\n%s", name, s.index, d)
+		return nil
 	}
 	s.index++
 	return c.Result
 }
 
-// T returns *testing.T.
+// T returns Reporter, which is given to NewSequence.
 // This is called by mock code.
-func (s *Sequence) T() *testing.T {
+func (s *Sequence) T() Reporter {
 	return s.t
 }
 
@@ -77,7 +164,54 @@ func (s *Sequence) T() *testing.T {
 // This is called by test code.
 func (s *Sequence) IsEnd() {
 	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
 	if s.index < len(s.calls) {
-		s.t.Fatalf("there are non-proceeded calles: %+v", s.calls[s.index:])
+		s.t.Fatalf("%s", s.leftovers())
+	}
+}
+
+// endCheck is registered with Cleanup of Reporter by NewSequence.
+// It checks the sequence has end, when IsEnd was not called explicitly.
+func (s *Sequence) endCheck() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.closed.Store(true)
+	if s.skipEnd || s.ended || s.failed {
+		return
+	}
+	if s.index < len(s.calls) {
+		s.t.Errorf("%s", s.leftovers())
+	}
+}
+
+// leftovers describes calls which have not been proceeded.
+func (s *Sequence) leftovers() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "there are %d non-proceeded calls:", len(s.calls)-s.index)
+	for i := s.index; i < len(s.calls); i++ {
+		c := s.calls[i]
+		fmt.Fprintf(b, "\n\t#%d %s %+v", i, nameOf(c.Parameter), c.Parameter)
+	}
+	return b.String()
+}
+
+// namer is implemented by generated parameter types, to tell the method's
+// name.
+type namer interface{ M__() string }
+
+// nameOf returns a method name for a parameter.
+func nameOf(param interface{}) string {
+	if n, ok := param.(namer); ok {
+		return n.M__()
+	}
+	if param == nil {
+		return "(nil)"
+	}
+	typ := reflect.TypeOf(param)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
 	}
+	return strings.TrimSuffix(typ.Name(), "_P")
 }
@@ -0,0 +1,163 @@
+package mockrt
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/koron/mockgo/internal/comparer"
+	"github.com/koron/mockgo/mockrt3"
+)
+
+type helloP struct{ Name string }
+
+type helloR struct{ Out0 error }
+
+func TestSequenceAutoEnd(t *testing.T) {
+	s := NewSequence(t, Call{Parameter: helloP{Name: "foo"}, Result: helloR{}})
+	s.Call("Foo.Hello", helloP{Name: "foo"})
+}
+
+func TestSequenceLeftovers(t *testing.T) {
+	s := NewSequence(t,
+		Call{Parameter: helloP{Name: "foo"}, Result: helloR{}},
+		Call{Parameter: helloP{Name: "bar"}, Result: helloR{}},
+	).SkipEndCheck()
+	s.Call("Foo.Hello", helloP{Name: "foo"})
+	got := s.leftovers()
+	want := "there are 1 non-proceeded calls:\n\t#1 helloP {Name:bar}"
+	if got != want {
+		t.Errorf("unexpected leftovers:\nwant=%q\ngot=%q", want, got)
+	}
+}
+
+// hello is a mock method for test.
+func hello(s *Sequence, name string) error {
+	r, _ := s.Call("Foo.Hello", helloP{Name: name}).(helloR)
+	return r.Out0
+}
+
+func TestSequenceJournal(t *testing.T) {
+	s := NewSequence(t,
+		Call{Parameter: helloP{Name: "foo"}, Result: helloR{}},
+		Call{Parameter: helloP{Name: "bar"}, Result: helloR{}},
+	)
+	_, _, line, _ := runtime.Caller(0)
+	hello(s, "foo")
+	hello(s, "bar")
+	recs := s.Journal()
+	if len(recs) != 2 {
+		t.Fatalf("unexpected number of records: %d", len(recs))
+	}
+	if want := fmt.Sprintf("mockrt_test.go:%d", line+1); recs[0].Site != want {
+		t.Errorf("unexpected site: want=%s got=%s", want, recs[0].Site)
+	}
+	if recs[0].Goroutine == 0 || recs[0].Goroutine != recs[1].Goroutine {
+		t.Errorf("unexpected goroutines: %d %d", recs[0].Goroutine, recs[1].Goroutine)
+	}
+	if n := s.Count("Foo.Hello"); n != 2 {
+		t.Errorf("unexpected count: %d", n)
+	}
+	want := []interface{}{helloP{Name: "foo"}, helloP{Name: "bar"}}
+	if d := cmp.Diff(want, s.Parameters("Foo.Hello")); d != "" {
+		t.Errorf("unexpected parameters: -want +got\n%s", d)
+	}
+}
+
+// fakeReporter is a Reporter which records reported errors.
+type fakeReporter struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (*fakeReporter) Helper() {}
+
+func (fr *fakeReporter) Errorf(format string, args ...interface{}) {
+	fr.errors = append(fr.errors, fmt.Sprintf(format, args...))
+}
+
+func (fr *fakeReporter) Fatalf(format string, args ...interface{}) {
+	fr.errors = append(fr.errors, fmt.Sprintf(format, args...))
+}
+
+func (fr *fakeReporter) Cleanup(fn func()) {
+	fr.cleanups = append(fr.cleanups, fn)
+}
+
+func TestSequenceLate(t *testing.T) {
+	fr := &fakeReporter{}
+	s := NewSequence(fr)
+	fr.cleanups[0]()
+	if err := hello(s, "foo"); err != nil {
+		t.Errorf("unexpected result for late call: %v", err)
+	}
+	if len(fr.errors) != 0 {
+		t.Errorf("late call should not be reported to ended test: %q", fr.errors)
+	}
+	next := &fakeReporter{}
+	NewSequence(next)
+	if len(next.errors) != 1 || !strings.HasPrefix(next.errors[0], "leaked goroutine: Foo.Hello {Name:foo} was called after the end of test") {
+		t.Errorf("late call should be reported to next test: %q", next.errors)
+	}
+}
+
+func TestSequenceLateShared(t *testing.T) {
+	var lates []mockrt3.Late
+	mockrt3.HandleLate(func(l mockrt3.Late) { lates = append(lates, l) })
+	defer HandleLate(nil)
+	fr := &fakeReporter{}
+	s := NewSequence(fr)
+	fr.cleanups[0]()
+	hello(s, "foo")
+	if len(lates) != 1 || lates[0].Name != "Foo.Hello" {
+		t.Errorf("late call should be passed to the shared handler: %+v", lates)
+	}
+}
+
+type secret struct{ key string }
+
+type openP struct {
+	Name   string
+	Secret secret
+}
+
+func TestRegisterComparer(t *testing.T) {
+	RegisterComparer(func(a, b secret) bool { return true })
+	defer comparer.Reset()
+	s := NewSequence(t, Call{Parameter: openP{Secret: secret{key: "a"}}, Result: helloR{}})
+	s.Call("Foo.Open", openP{Secret: secret{key: "b"}})
+
+	fr := &fakeReporter{}
+	s = NewSequence(fr, Call{Parameter: openP{Secret: secret{key: "a"}}, Result: helloR{}}).
+		RegisterComparer(func(a, b secret) bool { return a.key == b.key })
+	s.Call("Foo.Open", openP{Secret: secret{key: "b"}})
+	if len(fr.errors) != 1 {
+		t.Errorf("comparer of Sequence should take precedence: %q", fr.errors)
+	}
+	s.RegisterComparer(func(a secret) bool { return true })
+	if len(fr.errors) != 2 || fr.errors[1] != "comparer should be func(T, T) bool, got func(mockrt.secret) bool" {
+		t.Errorf("unexpected errors: %q", fr.errors)
+	}
+}
+
+func TestRegisterComparerShared(t *testing.T) {
+	mockrt3.RegisterComparer(func(a, b secret) bool { return true })
+	defer comparer.Reset()
+	s := NewSequence(t, Call{Parameter: openP{Secret: secret{key: "a"}}, Result: helloR{}})
+	s.Call("Foo.Open", openP{Secret: secret{key: "b"}})
+}
+
+func TestCallWithOption(t *testing.T) {
+	s := NewSequence(t,
+		Call{Parameter: openP{Name: "a"}, Result: helloR{}}.WithOption(cmpopts.IgnoreFields(openP{}, "Name")),
+		Call{Parameter: openP{Name: "a"}, Result: helloR{}},
+	).WithOption(cmpopts.IgnoreUnexported(secret{}))
+	s.Call("Foo.Open", openP{Name: "b", Secret: secret{key: "x"}})
+	s.Call("Foo.Open", openP{Name: "a", Secret: secret{key: "y"}})
+	if len(s.opts) != 1 {
+		t.Errorf("options of a call should not be added to Sequence: %d", len(s.opts))
+	}
+}
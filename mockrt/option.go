@@ -2,9 +2,88 @@ package mockrt
 
 import (
 	"context"
+	"io"
+	"reflect"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/koron/mockgo/internal/comparer"
 )
 
 // IgnoreContext is an option to ignore context.Context.
 var IgnoreContext = cmpopts.IgnoreInterfaces(struct{ context.Context }{})
+
+// EquateErrors is an option to compare errors with errors.Is.
+var EquateErrors = cmpopts.EquateErrors()
+
+// EquateEmpty is an option to treat empty and nil slices or maps as equal.
+var EquateEmpty = cmpopts.EquateEmpty()
+
+// EquateTime is an option to compare time.Time with its Equal method.
+var EquateTime = cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })
+
+// ReaderIdentity is an option to compare io.Reader by identity, instead of
+// contents of the values.
+var ReaderIdentity = cmp.Comparer(func(a, b io.Reader) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || !va.Comparable() {
+		return false
+	}
+	return a == b
+})
+
+// Defaults is a bundle of standard options.
+var Defaults = cmp.Options{
+	IgnoreContext,
+	EquateErrors,
+	EquateEmpty,
+	EquateTime,
+	ReaderIdentity,
+}
+
+// RegisterComparer registers a process-wide comparer for type T, which is
+// used by all Sequence and mockrt3.Q.  fn must be a func(T, T) bool.  A
+// comparer which was registered for same type is replaced.  This panics when
+// fn is invalid.
+func RegisterComparer(fn interface{}) {
+	if err := comparer.Register(fn); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterComparer registers a comparer for type T, which is used by the
+// sequence.  fn must be a func(T, T) bool.  This takes precedence over
+// comparers which were registered by the package function RegisterComparer.
+// This is called by test codes.
+func (s *Sequence) RegisterComparer(fn interface{}) *Sequence {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.comparers.Add(fn); err != nil {
+		s.failed = true
+		s.t.Fatalf("%s", err)
+	}
+	return s
+}
+
+// WithOption adds compare options for the call, in addition to options of
+// Sequence.
+// This is called by test codes.
+func (c Call) WithOption(opts ...cmp.Option) Call {
+	c.opts = append(c.opts[:len(c.opts):len(c.opts)], opts...)
+	return c
+}
+
+// options returns options to compare the parameter of c.
+func (s *Sequence) options(c Call) []cmp.Option {
+	opts := comparer.Options(s.comparers)
+	if opts == nil && len(c.opts) == 0 {
+		return s.opts
+	}
+	opts = append(opts, s.opts...)
+	return append(opts, c.opts...)
+}
@@ -0,0 +1,74 @@
+package mockrt3
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// notify wakes up goroutines which wait for changes of Q.
+// This must be called with q.mu locked.
+func (q *Q) notify() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
+
+// Wait blocks until all calls in Q have been proceeded, or Q has failed.
+// This returns ctx.Err() when ctx is done before it.
+// This is useful when the code under test makes calls from goroutines.
+// This is called by test codes.
+func (q *Q) Wait(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if q.index >= len(q.calls) || q.failed.Load() {
+			q.mu.Unlock()
+			return nil
+		}
+		if q.changed == nil {
+			q.changed = make(chan struct{})
+		}
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WaitFor waits like Wait for at most timeout.  It reports a failure with
+// non-proceeded calls on timeout.
+// This is called by test codes.
+func (q *Q) WaitFor(timeout time.Duration) {
+	q.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if q.Wait(ctx) == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.fail("timed out after %s, %s", timeout, q.leftovers())
+}
+
+// Notify makes the call to close ch when the call is made and matches.
+// This is called by test codes.
+//
+//	arrived := make(chan struct{})
+//	q.AddCall(FooHello_M.C(FooHello_P{Name: "a"}, FooHello_R{}).Notify(arrived))
+//	go target.Run()
+//	<-arrived
+func (c C) Notify(ch chan<- struct{}) C {
+	return c.with(func(x *extra) { x.notify, x.notified = ch, &sync.Once{} })
+}
+
+// arrived notifies that the call is made.  ch is closed only once, even when
+// the call is made repeatedly by Loop.
+func (c C) arrived() {
+	if c.extra != nil && c.extra.notify != nil {
+		c.extra.notified.Do(func() { close(c.extra.notify) })
+	}
+}
@@ -0,0 +1,65 @@
+package mockrt3
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koron/mockgo/internal/caller"
+)
+
+// mark is a named position in a sequence of calls.
+type mark struct {
+	pos  int
+	site string
+}
+
+// Mark puts a named checkpoint at the end of calls which were added so far.
+// Use Checkpoint to verify that progress of calls reached to it.
+// This is called by test codes.
+//
+//	q.AddCall(setupCalls...).Mark("setup")
+//	q.AddCall(actCalls...).Mark("act")
+func (q *Q) Mark(name string) *Q {
+	q.t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if m, ok := q.marks[name]; ok {
+		q.fail("checkpoint %q is marked already at #%d (%s)", name, m.pos, m.site)
+		return q
+	}
+	if q.marks == nil {
+		q.marks = map[string]mark{}
+	}
+	q.marks[name] = mark{pos: len(q.calls), site: caller.Site(1)}
+	return q
+}
+
+// Checkpoint checks that all calls which were added before a checkpoint
+// given by Mark have been proceeded, and no calls after it have been
+// proceeded yet.
+// This is called by test codes.
+func (q *Q) Checkpoint(name string) {
+	q.t.Helper()
+	if q.learn {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m, ok := q.marks[name]
+	if !ok {
+		q.fail("checkpoint %q is not marked", name)
+		return
+	}
+	b := &strings.Builder{}
+	switch {
+	case q.index < m.pos:
+		fmt.Fprintf(b, "checkpoint %q (%s) is not reached, there are %d non-proceeded calls:", name, m.site, m.pos-q.index)
+		q.describe(b, q.index, m.pos)
+	case q.index > m.pos:
+		fmt.Fprintf(b, "checkpoint %q (%s) is passed, there are %d calls proceeded after it:", name, m.site, q.index-m.pos)
+		q.describe(b, m.pos, q.index)
+	default:
+		return
+	}
+	q.fail("%s", b.String())
+}
@@ -0,0 +1,146 @@
+package mockrt3
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/koron/mockgo/internal/comparer"
+)
+
+// equaler is implemented by generated P types.  Eq__ compares fields with ==
+// where it is possible, and ignores fields of func types like ignorer.
+type equaler interface{ Eq__(P) bool }
+
+// ignorer is implemented by generated P types, which have fields of func
+// types.  Those fields are ignored on comparison, because cmp can't compare
+// funcs unless they are nil.
+type ignorer interface{ Ignored__() []string }
+
+// options returns options to compare parameters of c with others.
+func (q *Q) options(c C) []cmp.Option {
+	opts := comparer.Options(q.comparers)
+	ig, ok := c.P.(ignorer)
+	if !ok && c.extra == nil && opts == nil {
+		return q.opts
+	}
+	opts = append(opts, q.opts...)
+	if c.extra != nil {
+		opts = append(opts, c.extra.opts...)
+	}
+	if ok {
+		opts = append(opts, cmpopts.IgnoreFields(c.P, ig.Ignored__()...))
+	}
+	return opts
+}
+
+// hasOptions checks compare options are given for c by users.
+func (q *Q) hasOptions(c C) bool {
+	return len(q.opts) > 0 || (c.extra != nil && len(c.extra.opts) > 0) || comparer.Registered(q.comparers)
+}
+
+// equal compares parameters of c with got, without building differences.
+//
+// This uses Eq__ of P to avoid reflection when it is available and no options
+// are given by users, otherwise this uses cmp.Equal.  This returns an error
+// instead of a panic of cmp, when parameters can't be compared.
+func (q *Q) equal(c C, got P) (eq bool, err error) {
+	want := c.P
+	defer func() {
+		if r := recover(); r != nil {
+			err = uncomparable(want, r)
+		}
+	}()
+	if e, ok := want.(equaler); ok && !q.hasOptions(c) {
+		return e.Eq__(got), nil
+	}
+	return cmp.Equal(want, got, q.options(c)...), nil
+}
+
+// Equal compares x and y like cmp.Equal without options.  Eq__ of generated
+// P uses this for fields which can't be compared with ==.
+// This is called by mock code.
+func Equal(x, y interface{}) bool {
+	return cmp.Equal(x, y)
+}
+
+// EqualSlice compares slices x and y of basic types like cmp.Equal without
+// options, but without reflection.  A nil slice and an empty slice are not
+// equal.
+// This is called by mock code.
+func EqualSlice[T comparable](x, y []T) bool {
+	if (x == nil) != (y == nil) || len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compare compares parameters of c with got.  This returns "" when they are
+// equal, otherwise differences of them.  Use equal instead, when differences
+// are not reported.
+func (q *Q) compare(c C, got P) (d string, err error) {
+	eq, err := q.equal(c, got)
+	if err != nil || eq {
+		return "", err
+	}
+	return cmp.Diff(c.P, got, q.options(c)...), nil
+}
+
+// uncomparable describes why cmp panics to compare p with r.
+func uncomparable(p P, r interface{}) error {
+	rv := reflect.ValueOf(p)
+	if !rv.IsValid() {
+		return fmt.Errorf("cmp panics: %v", r)
+	}
+	path, typ := findUnexported(rv, rv.Type().String(), 0)
+	if typ == nil {
+		return fmt.Errorf("cmp panics: %v", r)
+	}
+	return fmt.Errorf("%s has unexported fields of %s, use an option like cmpopts.IgnoreUnexported(%[2]s{}), cmp.AllowUnexported(%[2]s{}) or cmp.Comparer with WithOption\ncmp panics: %v", path, typ, r)
+}
+
+// maxUnexportedDepth limits depth of findUnexported, to avoid cycles.
+const maxUnexportedDepth = 8
+
+// findUnexported finds a struct which has unexported fields in v, and returns
+// its path and type.
+func findUnexported(v reflect.Value, path string, depth int) (string, reflect.Type) {
+	if depth > maxUnexportedDepth {
+		return "", nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+		return findUnexported(v.Elem(), path, depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return "", nil
+		}
+		return findUnexported(v.Index(0), path+"[0]", depth+1)
+	case reflect.Struct:
+		typ := v.Type()
+		// cmp compares a type with Equal method by the method.
+		if _, ok := typ.MethodByName("Equal"); ok {
+			return "", nil
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if !typ.Field(i).IsExported() {
+				return path, typ
+			}
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if p, t := findUnexported(v.Field(i), path+"."+typ.Field(i).Name, depth+1); t != nil {
+				return p, t
+			}
+		}
+	}
+	return "", nil
+}
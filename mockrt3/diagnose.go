@@ -0,0 +1,89 @@
+package mockrt3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// timelineWidth is number of calls around a failure, which are shown in a
+// timeline.
+const timelineWidth = 2
+
+// match checks a call with name and param, which was made through q, matches
+// with e.
+func (q *Q) match(e entry, name string, param P) bool {
+	if e.label != q.label {
+		return false
+	}
+	c := e.C
+	if want, ok := methodName(c.P); ok && want != name {
+		return false
+	}
+	eq, err := q.equal(c, param)
+	return err == nil && eq
+}
+
+// diagnose describes why a call at #i with name and param failed.  This
+// searches other calls to find the call is out of order or duplicated, and
+// shows a timeline of calls around the failure.
+//
+// When the call matches #i+1, #i is marked as skipped.  Calls #i and #i+1
+// are told swapped, only when a later call matches the skipped #i.
+func (q *Q) diagnose(i int, name string, param P) string {
+	b := &strings.Builder{}
+	got := qualify(q.label, name)
+	for j := i + 1; j < len(q.calls); j++ {
+		if !q.match(q.calls[j], name, param) {
+			continue
+		}
+		if j == i+1 {
+			q.markSkipped(i)
+			fmt.Fprintf(b, "\nlooks like call #%d (%s) was skipped or is missing, the call matches #%d (%s)", i, q.calls[i].site, j, q.calls[j].site)
+		} else {
+			fmt.Fprintf(b, "\nlooks like call #%d (%s) is made earlier than expected", j, q.calls[j].site)
+		}
+		if i < len(q.calls) && q.calls[i].name() != got {
+			fmt.Fprintf(b, ": %s called before %s", got, q.calls[i].name())
+		}
+		break
+	}
+	for j := min(i, len(q.calls)) - 1; j >= 0; j-- {
+		if !q.match(q.calls[j], name, param) {
+			continue
+		}
+		if q.skipped[j] {
+			fmt.Fprintf(b, "\nlooks like calls #%d and #%d are swapped, it matches call #%d (%s) which was skipped", j, j+1, j, q.calls[j].site)
+		} else {
+			fmt.Fprintf(b, "\nlooks like duplicate call, it matches call #%d (%s) which was proceeded already", j, q.calls[j].site)
+		}
+		break
+	}
+	b.WriteString("\ntimeline (expected <- actual):")
+	start := max(0, i-timelineWidth)
+	end := min(len(q.calls), i+timelineWidth+1)
+	for j := start; j < end; j++ {
+		c := q.calls[j]
+		switch {
+		case j < i && q.skipped[j]:
+			fmt.Fprintf(b, "\n\t  #%d %s <- skipped", j, c.name())
+		case j < i:
+			fmt.Fprintf(b, "\n\t  #%d %s <- done", j, c.name())
+		case j == i:
+			fmt.Fprintf(b, "\n\t> #%d %s <- %s (%s)", j, c.name(), got, c.site)
+		default:
+			fmt.Fprintf(b, "\n\t  #%d %s", j, c.name())
+		}
+	}
+	if i >= len(q.calls) {
+		fmt.Fprintf(b, "\n\t> #%d (none) <- %s", i, got)
+	}
+	return b.String()
+}
+
+// markSkipped marks a call at #i as skipped by a failure.
+func (q *Q) markSkipped(i int) {
+	if q.skipped == nil {
+		q.skipped = map[int]bool{}
+	}
+	q.skipped[i] = true
+}
@@ -0,0 +1,56 @@
+package mockrt3
+
+import "strings"
+
+// Fallback sets a default result of a method, for calls which are not
+// expected.  Such calls don't consume the sequence of calls, don't fail the
+// test, but return r and are recorded in the journal.
+// This is called by test codes.
+func (q *Q) Fallback(name string, r R) *Q {
+	q.t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if rn, ok := methodName(r); ok && rn != name {
+		q.fail("fallback for %s has R for %s", name, rn)
+		return q
+	}
+	if q.fallbacks == nil {
+		q.fallbacks = map[string]R{}
+	}
+	q.fallbacks[name] = r
+	return q
+}
+
+// Lenient makes Q "nice" for unexpected calls.  Such calls don't consume the
+// sequence of calls, don't fail the test, but return results which were given
+// by Fallback or zero values, and are recorded in the journal.
+//
+// The argument names accepts names of methods like "Foo.Hello", or names of
+// mocks like "Foo" for all methods of the mock.  Without names, all calls to
+// Q are lenient.
+// This is called by test codes.
+func (q *Q) Lenient(names ...string) *Q {
+	if q.lenient == nil {
+		q.lenient = map[string]bool{}
+	}
+	if len(names) == 0 {
+		q.lenient[""] = true
+	}
+	for _, name := range names {
+		q.lenient[name] = true
+	}
+	return q
+}
+
+// fallback returns a result for an unexpected call of a method, when the
+// method has a fallback or is lenient.
+func (q *Q) fallback(name string) (R, bool) {
+	if r, ok := q.fallbacks[name]; ok {
+		return r, true
+	}
+	typn, _, _ := strings.Cut(name, ".")
+	if q.lenient[""] || q.lenient[name] || q.lenient[typn] {
+		return nil, true
+	}
+	return nil, false
+}
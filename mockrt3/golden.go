@@ -0,0 +1,77 @@
+package mockrt3
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("mockrt3.update", false, "update golden files of mockrt3")
+
+// Golden makes Q to compare a transcript of calls with a golden file at the
+// end of the test.  The golden file is rewritten when -mockrt3.update flag is
+// given to the test.
+//
+// This enables learn mode of Q, so Q accepts any calls.  Use Learn to provide
+// results for calls.
+// This is called by test codes.
+func (q *Q) Golden(name string) *Q {
+	q.learn = true
+	q.golden = name
+	return q
+}
+
+// transcript returns a transcript of calls, each element describes a call.
+func (q *Q) transcript() []string {
+	blocks := make([]string, 0, len(q.journal))
+	for i, rec := range q.journal {
+		p, r := rec.literals()
+		blocks = append(blocks, fmt.Sprintf("#%d %s\nP: %s\nR: %s\n", i, rec.Name, p, r))
+	}
+	return blocks
+}
+
+// checkGolden compares a transcript of calls with the golden file, or
+// rewrites the golden file.
+func (q *Q) checkGolden() {
+	q.t.Helper()
+	if q.golden == "" {
+		return
+	}
+	got := q.transcript()
+	if *update {
+		err := os.MkdirAll(filepath.Dir(q.golden), 0777)
+		if err == nil {
+			err = os.WriteFile(q.golden, []byte(strings.Join(got, "\n")), 0666)
+		}
+		if err != nil {
+			q.t.Errorf("failed to update golden file: %s", err)
+		}
+		return
+	}
+	b, err := os.ReadFile(q.golden)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			q.t.Errorf("golden file %s not found, run test with -mockrt3.update to create it", q.golden)
+			return
+		}
+		q.t.Errorf("failed to read golden file: %s", err)
+		return
+	}
+	var want []string
+	if len(b) > 0 {
+		want = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n\n")
+		for i, s := range want {
+			want[i] = s + "\n"
+		}
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		q.t.Errorf("transcript of calls doesn't match with golden file %s: -want +got\n%s", q.golden, d)
+	}
+}
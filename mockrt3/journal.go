@@ -0,0 +1,105 @@
+package mockrt3
+
+import (
+	"time"
+
+	"github.com/koron/mockgo/internal/caller"
+)
+
+// Record is a record of a call, which was made to Q.
+type Record struct {
+	// Name is name of the called method, like "Foo.Hello".
+	Name string
+
+	// Label is a label of Q which the call was made through.  See Labeled.
+	Label string
+
+	// P is parameters of the call.
+	P P
+
+	// R is results which were returned to the caller. This is nil when the
+	// call was failed.
+	R R
+
+	// Time is the time when the call was made.
+	Time time.Time
+
+	// Goroutine is ID of a goroutine which made the call.
+	Goroutine int64
+
+	// Site is "file:line" of the caller of the mock method.
+	Site string
+}
+
+// record appends a Record for a call to the journal.  The argument skip is
+// the number of stack frames to ascend to the caller of the mock method, with
+// 0 identifying the caller of record.  This returns nil when the journal is
+// disabled by NoJournal.
+func (q *Q) record(name string, param P, skip int) *Record {
+	if q.noJournal && !q.learn {
+		return nil
+	}
+	rec := &Record{
+		Name:      name,
+		Label:     q.label,
+		P:         param,
+		Time:      time.Now(),
+		Goroutine: caller.Goroutine(),
+		Site:      caller.Site(skip + 1),
+	}
+	q.mu.Lock()
+	q.journal = append(q.journal, rec)
+	q.mu.Unlock()
+	return rec
+}
+
+// finish sets results of a call to rec, and returns them.
+func (q *Q) finish(rec *Record, r R) R {
+	if rec == nil {
+		return r
+	}
+	q.mu.Lock()
+	rec.R = r
+	q.mu.Unlock()
+	return r
+}
+
+// Journal returns records of all calls which were made to Q in order.
+// This is called by test codes.
+func (q *Q) Journal() []Record {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	recs := make([]Record, len(q.journal))
+	for i, rec := range q.journal {
+		recs[i] = *rec
+	}
+	return recs
+}
+
+// Count returns number of calls which were made for a method.
+// This is called by test codes.
+func (q *Q) Count(name string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n int
+	for _, rec := range q.journal {
+		if rec.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+// Params returns parameters of calls which were made for a method in order.
+// This is called by test codes.
+func (q *Q) Params(name string) []P {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var params []P
+	for _, rec := range q.journal {
+		if rec.Name == name {
+			params = append(params, rec.P)
+		}
+	}
+	return params
+}
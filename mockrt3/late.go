@@ -0,0 +1,34 @@
+package mockrt3
+
+import "github.com/koron/mockgo/internal/late"
+
+// Late is a call which was made to Q after the end of the test, typically by
+// a goroutine which outlives the test.  This is same type with mockrt.Late.
+type Late = late.Call
+
+// HandleLate sets a process-wide handler for calls which are made after the
+// end of the test.  fn may be called from any goroutines.  The handler is
+// shared with mockrt.HandleLate, so it receives late calls of both mockrt and
+// mockrt3.
+//
+// By default (or when fn is nil), late calls are reported as failures of the
+// next test which calls NewQ or mockrt.NewSequence.  Late calls never call
+// Reporter of the ended test, and return zero values.
+func HandleLate(fn func(Late)) {
+	late.Handle(fn)
+}
+
+// late checks the test which Q belongs to has ended.  When it has ended, this
+// records the call as Late and returns true.
+func (q *Q) late(name string, param P) bool {
+	if !q.closed.Load() {
+		return false
+	}
+	q.addLate(name, param)
+	return true
+}
+
+// addLate records a call as Late.
+func (q *Q) addLate(name string, param P) {
+	late.Add(late.New(q.t, name, param))
+}
@@ -0,0 +1,82 @@
+package mockrt3
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Provider provides results for a call, in learn mode.
+// Return nil to make a mock to return zero values.
+type Provider func(name string, param P) R
+
+// logger is implemented by testing.TB.
+type logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// Learn makes Q to learn calls.  In learn mode, Q accepts any calls and
+// returns results which are provided by fn.  When fn is nil, a mock which
+// wraps a real implementation returns results of it, and other mocks return
+// zero values.
+//
+// Learned calls can be obtained as Go source code with Learned or
+// WriteLearned.  They are logged at the end of the test also, when Reporter
+// has Logf method like testing.TB, unless SkipEndCheck is called.
+// This is called by test codes.
+func (q *Q) Learn(fn Provider) *Q {
+	q.learn = true
+	q.provider = fn
+	return q
+}
+
+// learnCall accepts a call in learn mode.
+func (q *Q) learnCall(name string, param P) R {
+	if q.provider == nil {
+		return nil
+	}
+	return q.provider(name, param)
+}
+
+// Learned returns Go source code of learned calls as []mockrt3.C.
+// This is called by test codes.
+func (q *Q) Learned() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.learned()
+}
+
+// learned returns Go source code of learned calls.
+func (q *Q) learned() string {
+	b := &strings.Builder{}
+	b.WriteString("[]mockrt3.C{\n")
+	for _, rec := range q.journal {
+		p, r := rec.literals()
+		fmt.Fprintf(b, "\t{P: %s, R: %s},\n", p, r)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// WriteLearned writes Go source code of learned calls to a file.
+// This is called by test codes.
+func (q *Q) WriteLearned(name string) error {
+	return os.WriteFile(name, []byte(q.Learned()), 0666)
+}
+
+// logLearned logs learned calls at the end of the test.
+func (q *Q) logLearned() {
+	if l, ok := q.t.(logger); ok {
+		l.Logf("learned calls:\n%s", q.learned())
+	}
+}
+
+// endLearn finishes learn mode at the end of the test.
+func (q *Q) endLearn() {
+	q.t.Helper()
+	q.checkGolden()
+	q.writeTape()
+	if q.golden == "" && q.tape == "" && !q.skipEnd {
+		q.logLearned()
+	}
+}
@@ -0,0 +1,46 @@
+package mockrt3
+
+import "github.com/koron/mockgo/internal/caller"
+
+// Loop makes the sequence of calls cyclic.  After the last call, the next
+// call is checked with the first call again.  The check at the end of the
+// test passes when the last cycle has been completed.
+// This is useful for benchmarks which make same calls for each iteration.
+// This is called by test codes.
+func (q *Q) Loop() *Q {
+	q.loop = true
+	return q
+}
+
+// Repeat adds calls which are repeated k times.
+// This is called by test codes.
+func (q *Q) Repeat(k int, calls ...C) *Q {
+	q.t.Helper()
+	site := caller.Site(1)
+	for i := 0; i < k; i++ {
+		q.addCall(site, calls)
+	}
+	return q
+}
+
+// Reset rewinds the sequence of calls to the first call, and clears the
+// journal.  Calls in Q are kept, and no memory is reallocated.
+// This is called by test codes.
+func (q *Q) Reset() *Q {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.index = 0
+	clear(q.skipped)
+	clear(q.journal)
+	q.journal = q.journal[:0]
+	return q
+}
+
+// NoJournal disables the journal of calls, to reduce overhead of calls in
+// benchmarks.  Journal, Count and Params return nothing then.  Learn mode
+// ignores this.
+// This is called by test codes.
+func (q *Q) NoJournal() *Q {
+	q.noJournal = true
+	return q
+}
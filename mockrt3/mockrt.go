@@ -4,9 +4,16 @@ Package mockrt3 provides mock runtime for mockgo
 package mockrt3
 
 import (
-	"testing"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/koron/mockgo/internal/caller"
+	"github.com/koron/mockgo/internal/comparer"
+	"github.com/koron/mockgo/internal/late"
 )
 
 // P is a trait for types of request parameter.
@@ -17,60 +24,284 @@ type R interface{ R__() }
 
 // C defines pair of request parameter (P) and response result (R) for a method
 // call.
+//
+// Use Delay, RandomDelay, Block and OnCancel to simulate latency of the call,
+// and WithOption to add compare options for the call.
 type C struct {
 	P P
 	R R
+
+	extra *extra
+}
+
+// Reporter is a reporter of failures, which Q uses.
+// testing.TB (*testing.T, *testing.B and *testing.F) satisfies this.
+//
+// When Reporter has Cleanup(func()) method like testing.TB, Q checks end of
+// calls automatically at the end of the test.
+type Reporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// cleaner is implemented by testing.TB.
+type cleaner interface {
+	Cleanup(func())
+}
+
+// entry is a call in Q, with a location where it was declared, and a label
+// of Q which it was added through.
+type entry struct {
+	C
+	site  string
+	label string
 }
 
-// Q is a checker of sequence of method calls
+// name returns a name of the method with label, like "primary Foo.Hello".
+func (e entry) name() string {
+	return qualify(e.label, nameOf(e.P))
+}
+
+// Q is a checker of sequence of method calls.
+//
+// Q may be a labeled view of another Q, which shares the sequence of calls.
+// See Labeled.
 type Q struct {
-	t     *testing.T
-	calls []C
+	*state
+	label string
+}
+
+// state is a state of Q, which is shared among labeled views.
+type state struct {
+	t     Reporter
+	owner int64
+
+	// mu guards calls, index, journal, marks, skipped, changed, fallbacks,
+	// comparers and reports of failures, against calls from goroutines.
+	mu      sync.Mutex
+	changed chan struct{}
+
+	calls []entry
 	opts  []cmp.Option
 	index int
+
+	journal []*Record
+
+	marks map[string]mark
+
+	// skipped is a set of indexes of calls, which were skipped by failures.
+	skipped map[int]bool
+
+	learn    bool
+	provider Provider
+	golden   string
+	tape     string
+
+	strict bool
+	stubs  map[string]func(P) R
+
+	comparers comparer.Registry
+
+	lenient   map[string]bool
+	fallbacks map[string]R
+
+	loop      bool
+	noJournal bool
+
+	skipEnd  bool
+	nonFatal bool
+	ended    bool
+	failed   atomic.Bool
+	closed   atomic.Bool
 }
 
 // NewQ is an alias for NewSequence, creates a sequence of calls.
 // This is called by test codes.
-func NewQ(t *testing.T, calls ...C) *Q {
-	return &Q{
-		t:     t,
-		calls: calls,
+//
+// Q checks that all calls have been proceeded at the end of the test
+// automatically, when t supports Cleanup. Use SkipEndCheck to disable it.
+// Calls after the end of the test are handled as Late, see HandleLate.
+func NewQ(t Reporter, calls ...C) *Q {
+	t.Helper()
+	late.Report(t)
+	q := &Q{state: &state{t: t, owner: caller.Goroutine()}}
+	if c, ok := t.(cleaner); ok {
+		c.Cleanup(q.endCheck)
 	}
+	q.addCall(caller.Site(1), calls)
+	return q
 }
 
 // AddCall adds call data.
+// This checks P and R of each call belong to same method.
 // This is called by test codes.
 func (q *Q) AddCall(calls ...C) *Q {
-	q.calls = append(q.calls, calls...)
+	q.t.Helper()
+	q.addCall(caller.Site(1), calls)
 	return q
 }
 
-// WithOption updates compare option.
+// AddCallDepth adds call data like AddCall.  The argument calldepth is the
+// number of stack frames to ascend to determine where the calls were
+// declared, with 0 identifying the caller of AddCallDepth.
+// This is called by mock code.
+func (q *Q) AddCallDepth(calldepth int, calls ...C) *Q {
+	q.t.Helper()
+	q.addCall(caller.Site(calldepth+1), calls)
+	return q
+}
+
+// addCall adds calls which were declared at site.
+func (q *Q) addCall(site string, calls []C) {
+	q.t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.notify()
+	for _, c := range calls {
+		if err := checkPair(c.P, c.R); err != nil {
+			q.fail("call #%d (%s) has mismatched P and R: %s", len(q.calls), site, err)
+		}
+		q.calls = append(q.calls, entry{C: c, site: site, label: q.label})
+	}
+}
+
+// Labeled returns a view of Q with a label.  The view shares the sequence of
+// calls with Q and other views, so calls for mocks with different views are
+// checked in one global order.  Calls which are added through a view are
+// expected to be made through a view with the same label.
+// This is useful to tell instances of same mock type apart.
+// This is called by test codes.
+//
+//	primary := &DB{Q: q.Labeled("primary")}
+//	replica := &DB{Q: q.Labeled("replica")}
+func (q *Q) Labeled(label string) *Q {
+	return &Q{state: q.state, label: label}
+}
+
+// Label returns a label of Q, which was given to Labeled.
+func (q *Q) Label() string {
+	return q.label
+}
+
+// WithOption adds compare options.  Use Defaults for a bundle of standard
+// options.
 // This is called by test codes.
 func (q *Q) WithOption(opts ...cmp.Option) *Q {
-	q.opts = opts
+	q.opts = append(q.opts, opts...)
+	return q
+}
+
+// SkipEndCheck disables the automatic check at the end of the test, which
+// verifies all calls have been proceeded.
+// This is called by test codes.
+func (q *Q) SkipEndCheck() *Q {
+	q.skipEnd = true
+	return q
+}
+
+// NonFatal makes Q to record failures of calls with Errorf, instead of
+// Fatalf.  On failure, Call returns nil then a mock returns zero values, and
+// the test keeps going.  So a run surfaces every mismatch.
+// This is called by test codes.
+func (q *Q) NonFatal() *Q {
+	q.nonFatal = true
 	return q
 }
 
 // Call checks call parameter and returns result.
 // This is called by mock code.
 func (q *Q) Call(name string, param P) R {
+	if q.late(name, param) {
+		return nil
+	}
 	q.t.Helper()
-	if q.index >= len(q.calls) {
-		q.t.Fatalf("no calls at #%d for %s\nparam=%+v", q.index, name, param)
+	rec := q.record(name, param, 2)
+	return q.finish(rec, q.call(name, param))
+}
+
+// call checks call parameter and returns result.
+func (q *Q) call(name string, param P) R {
+	q.t.Helper()
+	// call Provider and wait for the result outside of the lock, to accept
+	// other calls.
+	if q.learn {
+		return q.learnCall(name, param)
+	}
+	return q.take(name, param).result(param)
+}
+
+// take checks call parameter and takes the call from the sequence.
+func (q *Q) take(name string, param P) C {
+	q.t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.takeLocked(name, param)
+}
+
+// takeLocked is take, which is called with q.mu locked.
+func (q *Q) takeLocked(name string, param P) C {
+	q.t.Helper()
+	defer q.notify()
+	// check the end of the test again with the lock, which endCheck holds.
+	if q.closed.Load() {
+		q.addLate(name, param)
+		return C{}
+	}
+	if q.loop && q.index >= len(q.calls) && len(q.calls) > 0 {
+		q.index = 0
 	}
-	c := q.calls[q.index]
-	if d := cmp.Diff(c.P, param, q.opts...); d != "" {
-		q.t.Fatalf("call for %s (#%d) has unexpected arguments: -want +got\n%s", name, q.index, d)
+	i := q.index
+	if r, ok := q.fallback(name); ok && (i >= len(q.calls) || !q.match(q.calls[i], name, param)) {
+		return C{R: r}
+	}
+	if i >= len(q.calls) {
+		q.fail("no calls at #%d for %s\nparam=%+v%s", i, name, param, q.diagnose(i, name, param))
+		return C{}
 	}
 	q.index++
-	return c.R
+	c := q.calls[i]
+	if want, ok := methodName(c.P); (ok && want != name) || c.label != q.label {
+		q.fail("unexpected call at #%d (%s): expected %s, got %s\nparam=%+v%s", i, c.site, c.name(), qualify(q.label, name), param, q.diagnose(i, name, param))
+		return C{}
+	}
+	d, err := q.compare(c.C, param)
+	if err != nil {
+		q.fail("call for %s (#%d, %s) can't be compared: %s", qualify(q.label, name), i, c.site, err)
+		return C{}
+	}
+	if d != "" {
+		q.fail("call for %s (#%d, %s) has unexpected arguments: -want +got\n%s%s", qualify(q.label, name), i, c.site, d, q.diagnose(i, name, param))
+		return C{}
+	}
+	c.arrived()
+	return c.C
+}
+
+// fail reports a failure of a call.
+// This uses Errorf in non-fatal mode or on goroutines other than one which
+// created Q, because Fatalf must be called from the goroutine running the
+// test.  Otherwise this uses Fatalf.
+//
+// This must be called with q.mu locked.  This reports nothing after the end
+// of the test, because Reporter must not be used then.  Calls are recorded as
+// Late by take instead.
+func (q *Q) fail(format string, args ...interface{}) {
+	q.t.Helper()
+	if q.closed.Load() {
+		return
+	}
+	if q.nonFatal || caller.Goroutine() != q.owner {
+		q.t.Errorf(format, args...)
+		return
+	}
+	q.failed.Store(true)
+	q.t.Fatalf(format, args...)
 }
 
-// T returns *testing.T.
+// T returns Reporter, which is given to NewQ.
 // This is called by mock code.
-func (q *Q) T() *testing.T {
+func (q *Q) T() Reporter {
 	return q.t
 }
 
@@ -78,7 +309,110 @@ func (q *Q) T() *testing.T {
 // This is called by test code.
 func (q *Q) IsEnd() {
 	q.t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ended = true
+	if q.learn {
+		q.endLearn()
+		return
+	}
+	if q.index < len(q.calls) {
+		q.t.Fatalf("%s", q.leftovers())
+	}
+}
+
+// endCheck is registered with Cleanup of Reporter by NewQ.
+// It checks the sequence has end, when IsEnd was not called explicitly.
+func (q *Q) endCheck() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.closed.Store(true)
+	if q.learn {
+		if !q.ended {
+			q.endLearn()
+		}
+		return
+	}
+	if q.skipEnd || q.ended || q.failed.Load() {
+		return
+	}
 	if q.index < len(q.calls) {
-		q.t.Fatalf("there are non-proceeded calles: %+v", q.calls[q.index:])
+		q.t.Errorf("%s", q.leftovers())
+	}
+}
+
+// leftovers describes calls which have not been proceeded.
+func (q *Q) leftovers() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "there are %d non-proceeded calls:", len(q.calls)-q.index)
+	q.describe(b, q.index, len(q.calls))
+	return b.String()
+}
+
+// describe writes calls from #start to #end-1 to b.
+func (q *Q) describe(b *strings.Builder, start, end int) {
+	for i := start; i < end; i++ {
+		c := q.calls[i]
+		fmt.Fprintf(b, "\n\t#%d %s %+v (%s)", i, c.name(), c.P, c.site)
+	}
+}
+
+// namer is implemented by generated P and R types, to tell the method's name.
+type namer interface{ M__() string }
+
+// methodName returns a method name which v (P or R) belongs to.
+func methodName(v interface{}) (string, bool) {
+	if n, ok := v.(namer); ok {
+		return n.M__(), true
+	}
+	return "", false
+}
+
+// nameOf returns a method name for a P.
+func nameOf(p P) string {
+	if name, ok := methodName(p); ok {
+		return name
+	}
+	if p == nil {
+		return "(nil)"
+	}
+	typ := reflect.TypeOf(p)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return strings.TrimSuffix(typ.Name(), "_P")
+}
+
+// qualify prefixes a method name with a label.
+func qualify(label, name string) string {
+	if label == "" {
+		return name
+	}
+	return label + " " + name
+}
+
+// checkPair checks p and r belong to same method.  When p or r doesn't tell
+// its method name, this depends on naming convention of generated types,
+// "FooBar_P" and "FooBar_R".
+func checkPair(p P, r R) error {
+	if p == nil || r == nil {
+		return nil
+	}
+	pn, pok := methodName(p)
+	rn, rok := methodName(r)
+	if pok && rok {
+		if pn != rn {
+			return fmt.Errorf("P is for %s, R is for %s", pn, rn)
+		}
+		return nil
+	}
+	pt, rt := reflect.TypeOf(p), reflect.TypeOf(r)
+	base, ok := strings.CutSuffix(pt.Name(), "_P")
+	if !ok {
+		return nil
+	}
+	if want := base + "_R"; rt.Name() != want || rt.PkgPath() != pt.PkgPath() {
+		return fmt.Errorf("want %s for R, got %s", want, rt.Name())
 	}
+	return nil
 }
@@ -0,0 +1,1131 @@
+package mockrt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/koron/mockgo/internal/comparer"
+)
+
+type hello_P struct{ Name string }
+
+func (hello_P) P__()        {}
+func (hello_P) M__() string { return "Foo.Hello" }
+
+func (p hello_P) Eq__(x P) bool {
+	o, ok := x.(hello_P)
+	return ok && p.Name == o.Name
+}
+
+type hello_R struct{ Out0 error }
+
+func (hello_R) R__()        {}
+func (hello_R) M__() string { return "Foo.Hello" }
+
+var rxSite = regexp.MustCompile(`mockrt_test\.go:\d+`)
+
+// stripSites replaces line numbers of sites in this file in s.
+func stripSites(s string) string {
+	return rxSite.ReplaceAllString(s, "mockrt_test.go:N")
+}
+
+func stripAllSites(ss []string) []string {
+	for i, s := range ss {
+		ss[i] = stripSites(s)
+	}
+	return ss
+}
+
+func TestQAutoEnd(t *testing.T) {
+	q := NewQ(t, C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	// the check at the end of this test should pass without IsEnd.
+}
+
+func TestQSkipEndCheck(t *testing.T) {
+	NewQ(t, C{P: hello_P{Name: "foo"}, R: hello_R{}}).SkipEndCheck()
+}
+
+func TestQLeftovers(t *testing.T) {
+	q := NewQ(t,
+		C{P: hello_P{Name: "foo"}, R: hello_R{}},
+		C{P: hello_P{Name: "bar"}, R: hello_R{}},
+	).SkipEndCheck()
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	got := stripSites(q.leftovers())
+	want := "there are 1 non-proceeded calls:\n\t#1 Foo.Hello {Name:bar} (mockrt_test.go:N)"
+	if got != want {
+		t.Errorf("unexpected leftovers:\nwant=%q\ngot=%q", want, got)
+	}
+}
+
+// fakeT is a Reporter which records reported failures.
+type fakeT struct {
+	errors   []string
+	fatals   []string
+	cleanups []func()
+}
+
+func (*fakeT) Helper() {}
+
+func (ft *fakeT) Errorf(format string, args ...interface{}) {
+	ft.errors = append(ft.errors, fmt.Sprintf(format, args...))
+}
+
+func (ft *fakeT) Fatalf(format string, args ...interface{}) {
+	ft.fatals = append(ft.fatals, fmt.Sprintf(format, args...))
+}
+
+func (ft *fakeT) Cleanup(fn func()) {
+	ft.cleanups = append(ft.cleanups, fn)
+}
+
+// end runs registered cleanup functions like end of a test.
+func (ft *fakeT) end() {
+	for i := len(ft.cleanups) - 1; i >= 0; i-- {
+		ft.cleanups[i]()
+	}
+}
+
+func TestQFakeT(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	r := q.Call("Foo.Hello", hello_P{Name: "bar"})
+	if r != nil {
+		t.Errorf("unexpected result for failed call: %+v", r)
+	}
+	if len(ft.fatals) != 1 {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+	ft.end()
+	if len(ft.errors) != 0 {
+		t.Errorf("end check should be skipped after failure: %q", ft.errors)
+	}
+}
+
+func TestQEndCheckFailure(t *testing.T) {
+	ft := &fakeT{}
+	NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	ft.end()
+	want := []string{"there are 1 non-proceeded calls:\n\t#0 Foo.Hello {Name:foo} (mockrt_test.go:N)"}
+	if d := cmp.Diff(want, stripAllSites(ft.errors)); d != "" {
+		t.Errorf("unexpected errors: -want +got\n%s", d)
+	}
+}
+
+func BenchmarkQ(b *testing.B) {
+	q := NewQ(b)
+	for i := 0; i < b.N; i++ {
+		q.AddCall(C{P: hello_P{Name: "foo"}, R: hello_R{}})
+		q.Call("Foo.Hello", hello_P{Name: "foo"})
+	}
+}
+
+// noEq_P is hello_P without Eq__, to compare parameters by cmp.
+type noEq_P struct{ Name string }
+
+func (noEq_P) P__()        {}
+func (noEq_P) M__() string { return "Foo.Hello" }
+
+func BenchmarkQCompare(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		p    P
+	}{
+		{"Eq__", hello_P{Name: "foo"}},
+		{"cmp", noEq_P{Name: "foo"}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			q := NewQ(b, C{P: tc.p, R: hello_R{}}).Loop().NoJournal()
+			for i := 0; i < b.N; i++ {
+				q.Call("Foo.Hello", tc.p)
+			}
+		})
+	}
+}
+
+// count_P is a P which counts calls of Eq__.
+type count_P struct {
+	Name string
+	n    *int
+}
+
+func (count_P) P__()        {}
+func (count_P) M__() string { return "Foo.Count" }
+
+func (p count_P) Eq__(x P) bool {
+	*p.n++
+	o, ok := x.(count_P)
+	return ok && p.Name == o.Name
+}
+
+type count_R struct{}
+
+func (count_R) R__()        {}
+func (count_R) M__() string { return "Foo.Count" }
+
+func TestQEq(t *testing.T) {
+	var n int
+	q := NewQ(t, C{P: count_P{Name: "foo", n: &n}, R: count_R{}})
+	// count_P has an unexported field, so cmp panics if it is used.
+	q.Call("Foo.Count", count_P{Name: "foo"})
+	if n != 1 {
+		t.Errorf("Eq__ should be used to compare parameters: %d", n)
+	}
+
+	n = 0
+	ft := &fakeT{}
+	q = NewQ(ft, C{P: count_P{Name: "foo", n: &n}, R: count_R{}}).WithOption(cmpopts.IgnoreUnexported(count_P{}))
+	q.Call("Foo.Count", count_P{Name: "foo"})
+	if n != 0 || len(ft.fatals) != 0 {
+		t.Errorf("Eq__ should not be used with options: %d %q", n, ft.fatals)
+	}
+}
+
+func TestEqualSlice(t *testing.T) {
+	for i, tc := range []struct{ x, y []int }{
+		{nil, nil},
+		{nil, []int{}},
+		{[]int{}, []int{}},
+		{[]int{1, 2}, []int{1, 2}},
+		{[]int{1, 2}, []int{1, 3}},
+		{[]int{1, 2}, []int{1}},
+	} {
+		if got, want := EqualSlice(tc.x, tc.y), cmp.Equal(tc.x, tc.y); got != want {
+			t.Errorf("unexpected result #%d %v %v: want=%t got=%t", i, tc.x, tc.y, want, got)
+		}
+	}
+}
+
+type bye_P struct{}
+
+func (bye_P) P__()        {}
+func (bye_P) M__() string { return "Foo.Bye" }
+
+type bye_R struct{}
+
+func (bye_R) R__()        {}
+func (bye_R) M__() string { return "Foo.Bye" }
+
+func TestQNonFatal(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft,
+		C{P: hello_P{Name: "foo"}, R: hello_R{}},
+		C{P: hello_P{Name: "bar"}, R: hello_R{}},
+		C{P: hello_P{Name: "baz"}, R: hello_R{}},
+	).NonFatal()
+	for _, name := range []string{"xxx", "bar", "baz", "qux"} {
+		r := q.Call("Foo.Hello", hello_P{Name: name})
+		if _, ok := r.(hello_R); !ok && r != nil {
+			t.Errorf("unexpected result type for %s: %T", name, r)
+		}
+	}
+	if len(ft.fatals) != 0 {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+	if len(ft.errors) != 2 {
+		t.Fatalf("unexpected errors: %q", ft.errors)
+	}
+}
+
+func TestQMismatchedPair(t *testing.T) {
+	ft := &fakeT{}
+	NewQ(ft).NonFatal().AddCall(
+		C{P: hello_P{Name: "foo"}, R: hello_R{}},
+		C{P: hello_P{Name: "bar"}, R: bye_R{}},
+	).SkipEndCheck()
+	want := []string{"call #1 (mockrt_test.go:N) has mismatched P and R: P is for Foo.Hello, R is for Foo.Bye"}
+	if d := cmp.Diff(want, stripAllSites(ft.errors)); d != "" {
+		t.Errorf("unexpected errors: -want +got\n%s", d)
+	}
+}
+
+func TestQMethodName(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}}).SkipEndCheck()
+	q.Call("Foo.Bye", bye_P{})
+	want := []string{"unexpected call at #0 (mockrt_test.go:N): expected Foo.Hello, got Foo.Bye\nparam={}" +
+		"\ntimeline (expected <- actual):" +
+		"\n\t> #0 Foo.Hello <- Foo.Bye (mockrt_test.go:N)"}
+	if d := cmp.Diff(want, stripAllSites(ft.fatals)); d != "" {
+		t.Errorf("unexpected fatals: -want +got\n%s", d)
+	}
+}
+
+func TestQDiagnose(t *testing.T) {
+	q := NewQ(t,
+		C{P: hello_P{Name: "a"}, R: hello_R{}},
+		C{P: hello_P{Name: "b"}, R: hello_R{}},
+		C{P: bye_P{}, R: bye_R{}},
+		C{P: hello_P{Name: "c"}, R: hello_R{}},
+	).SkipEndCheck()
+	q.Call("Foo.Hello", hello_P{Name: "a"})
+	for _, tc := range []struct {
+		name  string
+		param P
+		want  string
+	}{
+		{"Foo.Bye", bye_P{}, "\nlooks like call #1 (mockrt_test.go:N) was skipped or is missing, the call matches #2 (mockrt_test.go:N): Foo.Bye called before Foo.Hello" +
+			"\ntimeline (expected <- actual):" +
+			"\n\t  #0 Foo.Hello <- done" +
+			"\n\t> #1 Foo.Hello <- Foo.Bye (mockrt_test.go:N)" +
+			"\n\t  #2 Foo.Bye" +
+			"\n\t  #3 Foo.Hello"},
+		{"Foo.Hello", hello_P{Name: "c"}, "\nlooks like call #3 (mockrt_test.go:N) is made earlier than expected" +
+			"\ntimeline (expected <- actual):" +
+			"\n\t  #0 Foo.Hello <- done" +
+			"\n\t> #1 Foo.Hello <- Foo.Hello (mockrt_test.go:N)" +
+			"\n\t  #2 Foo.Bye" +
+			"\n\t  #3 Foo.Hello"},
+		{"Foo.Hello", hello_P{Name: "a"}, "\nlooks like duplicate call, it matches call #0 (mockrt_test.go:N) which was proceeded already" +
+			"\ntimeline (expected <- actual):" +
+			"\n\t  #0 Foo.Hello <- done" +
+			"\n\t> #1 Foo.Hello <- Foo.Hello (mockrt_test.go:N)" +
+			"\n\t  #2 Foo.Bye" +
+			"\n\t  #3 Foo.Hello"},
+	} {
+		got := stripSites(q.diagnose(1, tc.name, tc.param))
+		if got != tc.want {
+			t.Errorf("unexpected diagnosis for %s %+v:\nwant=%q\ngot=%q", tc.name, tc.param, tc.want, got)
+		}
+	}
+}
+
+func TestQDiagnoseSwapped(t *testing.T) {
+	for _, tc := range []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"b", "a"}, []string{
+			"looks like call #0 (mockrt_test.go:N) was skipped or is missing, the call matches #1 (mockrt_test.go:N)",
+			"looks like calls #0 and #1 are swapped, it matches call #0 (mockrt_test.go:N) which was skipped",
+		}},
+		{[]string{"b", "b"}, []string{
+			"looks like call #0 (mockrt_test.go:N) was skipped or is missing, the call matches #1 (mockrt_test.go:N)",
+		}},
+	} {
+		ft := &fakeT{}
+		q := NewQ(ft,
+			C{P: hello_P{Name: "a"}, R: hello_R{}},
+			C{P: hello_P{Name: "b"}, R: hello_R{}},
+		).NonFatal().SkipEndCheck()
+		for _, name := range tc.names {
+			q.Call("Foo.Hello", hello_P{Name: name})
+		}
+		var got []string
+		for _, e := range ft.errors {
+			for _, l := range strings.Split(stripSites(e), "\n") {
+				if strings.HasPrefix(l, "looks like") {
+					got = append(got, l)
+				}
+			}
+		}
+		if d := cmp.Diff(tc.want, got); d != "" {
+			t.Errorf("unexpected diagnoses for %v: -want +got\n%s", tc.names, d)
+		}
+	}
+}
+
+func TestQSite(t *testing.T) {
+	q := NewQ(t).SkipEndCheck()
+	_, _, line, _ := runtime.Caller(0)
+	q.AddCall(C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	if want := fmt.Sprintf("mockrt_test.go:%d", line+1); q.calls[0].site != want {
+		t.Errorf("unexpected site: want=%s got=%s", want, q.calls[0].site)
+	}
+}
+
+func TestQJournal(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft,
+		C{P: hello_P{Name: "foo"}, R: hello_R{}},
+		C{P: bye_P{}, R: bye_R{}},
+	).NonFatal()
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	q.Call("Foo.Hello", hello_P{Name: "bar"})
+	recs := q.Journal()
+	if len(recs) != 2 {
+		t.Fatalf("unexpected number of records: %d", len(recs))
+	}
+	if recs[0].R == nil || recs[1].R != nil {
+		t.Errorf("unexpected results: %+v %+v", recs[0].R, recs[1].R)
+	}
+	if n := q.Count("Foo.Hello"); n != 2 {
+		t.Errorf("unexpected count: %d", n)
+	}
+	want := []P{hello_P{Name: "foo"}, hello_P{Name: "bar"}}
+	if d := cmp.Diff(want, q.Params("Foo.Hello")); d != "" {
+		t.Errorf("unexpected params: -want +got\n%s", d)
+	}
+}
+
+func TestQLearn(t *testing.T) {
+	q := NewQ(t).Learn(func(name string, param P) R {
+		if p, ok := param.(hello_P); ok && p.Name == "bar" {
+			return hello_R{Out0: errors.New("unknown name")}
+		}
+		return nil
+	}).SkipEndCheck()
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	q.Call("Foo.Hello", hello_P{Name: "bar"})
+	q.Call("Foo.Bye", bye_P{})
+	want := "[]mockrt3.C{\n" +
+		"\t{P: hello_P{Name: \"foo\"}, R: hello_R{}},\n" +
+		"\t{P: hello_P{Name: \"bar\"}, R: hello_R{Out0: errors.New(\"unknown name\")}},\n" +
+		"\t{P: bye_P{}, R: bye_R{}},\n" +
+		"}\n"
+	if got := q.Learned(); got != want {
+		t.Errorf("unexpected learned calls:\nwant=%s\ngot=%s", want, got)
+	}
+}
+
+// logT is a fakeT which records logs.
+type logT struct {
+	fakeT
+	logs []string
+}
+
+func (lt *logT) Logf(format string, args ...interface{}) {
+	lt.logs = append(lt.logs, fmt.Sprintf(format, args...))
+}
+
+func TestQLearnLog(t *testing.T) {
+	lt := &logT{}
+	NewQ(lt).Learn(nil).Call("Foo.Hello", hello_P{Name: "foo"})
+	lt.end()
+	want := []string{"learned calls:\n[]mockrt3.C{\n\t{P: hello_P{Name: \"foo\"}, R: hello_R{}},\n}\n"}
+	if d := cmp.Diff(want, lt.logs); d != "" {
+		t.Errorf("unexpected logs: -want +got\n%s", d)
+	}
+
+	lt = &logT{}
+	NewQ(lt).Learn(nil).SkipEndCheck().Call("Foo.Hello", hello_P{Name: "foo"})
+	lt.end()
+	if len(lt.logs) != 0 {
+		t.Errorf("SkipEndCheck should suppress the log: %q", lt.logs)
+	}
+}
+
+func TestQGolden(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hello.golden")
+	run := func(ft *fakeT, names ...string) {
+		q := NewQ(ft).Golden(name)
+		for _, n := range names {
+			q.Call("Foo.Hello", hello_P{Name: n})
+		}
+		ft.end()
+	}
+
+	*update = true
+	run(&fakeT{}, "foo", "bar")
+	*update = false
+
+	ft := &fakeT{}
+	run(ft, "foo", "bar")
+	if len(ft.errors) != 0 {
+		t.Errorf("unexpected errors: %q", ft.errors)
+	}
+
+	ft = &fakeT{}
+	run(ft, "foo", "baz")
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], `P: hello_P{Name: "baz"}`) {
+		t.Errorf("unexpected errors: %q", ft.errors)
+	}
+}
+
+var testTypes = Types{
+	"Foo.Hello": {P: hello_P{}, R: hello_R{}},
+	"Foo.Bye":   {P: bye_P{}, R: bye_R{}},
+}
+
+func writeScenario(t *testing.T, s string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(name, []byte(s), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoad(t *testing.T) {
+	name := writeScenario(t, `[
+  { "method": "Foo.Hello", "P": { "Name": "foo" }, "R": { "Out0": null } },
+  { "method": "Foo.Hello", "P": { "Name": "bar" }, "R": { "Out0": "no bar" } },
+  { "method": "Foo.Bye" }
+]`)
+	q := Load(t, name, testTypes)
+	if r := q.Call("Foo.Hello", hello_P{Name: "foo"}); r != (hello_R{}) {
+		t.Errorf("unexpected result #0: %+v", r)
+	}
+	r := q.Call("Foo.Hello", hello_P{Name: "bar"}).(hello_R)
+	if r.Out0 == nil || r.Out0.Error() != "no bar" {
+		t.Errorf("unexpected result #1: %+v", r)
+	}
+	q.Call("Foo.Bye", bye_P{})
+	if site := q.calls[1].site; site != "scenario.json:3:3" {
+		t.Errorf("unexpected site: %s", site)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want string
+	}{
+		{`[{"method": "Foo.Hi"}]`, `scenario.json:1:2: unknown method "Foo.Hi"`},
+		{"[\n {\"method\": \"Foo.Hello\",\n  \"P\": {\"Nmae\": \"foo\"}}]", `scenario.json:3:9: unknown field "Nmae" in hello_P`},
+		{"[\n {\"method\": \"Foo.Hello\",\n  \"P\": {\"Name\": 123}}]", `scenario.json:3:17: field Name in hello_P: cannot use number as string`},
+		{`[{"method": "Foo.Hello", "R": {"Out0": 1}}]`, `scenario.json:1:40: field Out0 in hello_R: needs a string or null for error`},
+		{`[{"method": "Foo.Hello", "X": 1}]`, `scenario.json:1:26: unknown key "X" in a call`},
+	} {
+		q := &Q{state: &state{t: t}}
+		err := q.load(writeScenario(t, tc.s), testTypes)
+		if err == nil {
+			t.Errorf("no errors for %s", tc.s)
+			continue
+		}
+		if err.Error() != tc.want {
+			t.Errorf("unexpected error for %s:\nwant=%s\ngot=%s", tc.s, tc.want, err)
+		}
+	}
+}
+
+func TestRecordingReplay(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "tape.json")
+	real := func(p hello_P) func() R {
+		return func() R {
+			if p.Name == "bar" {
+				return hello_R{Out0: errors.New("no bar")}
+			}
+			return hello_R{}
+		}
+	}
+
+	ft := &fakeT{}
+	q := Recording(ft, name)
+	q.Forward("Foo.Hello", hello_P{Name: "foo"}, real(hello_P{Name: "foo"}))
+	q.Forward("Foo.Hello", hello_P{Name: "bar"}, real(hello_P{Name: "bar"}))
+	ft.end()
+	if len(ft.errors) != 0 {
+		t.Fatalf("failed to record: %q", ft.errors)
+	}
+
+	q = Replay(t, name, testTypes)
+	noReal := func() R {
+		t.Error("real implementation should not be called")
+		return nil
+	}
+	if r := q.Forward("Foo.Hello", hello_P{Name: "foo"}, noReal); r != (hello_R{}) {
+		t.Errorf("unexpected result #0: %+v", r)
+	}
+	r := q.Forward("Foo.Hello", hello_P{Name: "bar"}, noReal).(hello_R)
+	if r.Out0 == nil || r.Out0.Error() != "no bar" {
+		t.Errorf("unexpected result #1: %+v", r)
+	}
+}
+
+func TestQSpy(t *testing.T) {
+	var reals []string
+	spy := func(q *Q, name string) error {
+		r, _ := q.Forward("Foo.Hello", hello_P{Name: name}, func() R {
+			reals = append(reals, name)
+			return hello_R{}
+		}).(hello_R)
+		return r.Out0
+	}
+	errStub := errors.New("stub")
+	q := NewQ(t, C{P: hello_P{Name: "bar"}, R: hello_R{Out0: errStub}})
+	if err := spy(q, "foo"); err != nil {
+		t.Errorf("unexpected error for foo: %s", err)
+	}
+	if err := spy(q, "bar"); err != errStub {
+		t.Errorf("unexpected error for bar: %v", err)
+	}
+	q.Stub("Foo.Hello", func(P) R { return hello_R{Out0: errStub} })
+	if err := spy(q, "baz"); err != errStub {
+		t.Errorf("unexpected error for baz: %v", err)
+	}
+	if d := cmp.Diff([]string{"foo"}, reals); d != "" {
+		t.Errorf("unexpected real calls: -want +got\n%s", d)
+	}
+	if n := q.Count("Foo.Hello"); n != 3 {
+		t.Errorf("unexpected count: %d", n)
+	}
+}
+
+func TestQSpyConcurrent(t *testing.T) {
+	errStub := errors.New("stub")
+	for i := 0; i < 100; i++ {
+		q := NewQ(t, C{P: hello_P{Name: "foo"}, R: hello_R{Out0: errStub}})
+		var stubs, reals atomic.Int32
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r, _ := q.Forward("Foo.Hello", hello_P{Name: "foo"}, func() R {
+					reals.Add(1)
+					return hello_R{}
+				}).(hello_R)
+				if r.Out0 == errStub {
+					stubs.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if stubs.Load() != 1 || reals.Load() != 7 {
+			t.Fatalf("one call should be taken and another should be forwarded #%d: stubs=%d reals=%d", i, stubs.Load(), reals.Load())
+		}
+	}
+}
+
+func TestQSpyNoReal(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	if r := q.Forward("Foo.Hello", hello_P{Name: "bar"}, nil); r != nil {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	want := []string{"call for Foo.Hello can't be forwarded: Real of the mock is nil, set it or expect the call"}
+	if d := cmp.Diff(want, ft.fatals); d != "" {
+		t.Errorf("unexpected fatals: -want +got\n%s", d)
+	}
+}
+
+func TestQSpyNoDiff(t *testing.T) {
+	var n int
+	eq := cmp.Comparer(func(a, b hello_P) bool {
+		n++
+		return a.Name == b.Name
+	})
+	cmp.Equal(hello_P{Name: "bar"}, hello_P{Name: "foo"}, eq)
+	want := n
+	n = 0
+	q := NewQ(t, C{P: hello_P{Name: "bar"}, R: hello_R{}}).WithOption(eq).SkipEndCheck()
+	q.Forward("Foo.Hello", hello_P{Name: "foo"}, func() R { return hello_R{} })
+	// cmp.Diff calls the comparer again, when it is used to check forwarding.
+	if n != want {
+		t.Errorf("comparer should be called as cmp.Equal to check forwarding: want=%d got=%d", want, n)
+	}
+}
+
+// expectHello simulates generated expectation builder.
+func expectHello(q *Q, name string, out0 error) {
+	q.T().Helper()
+	q.AddCallDepth(1, C{P: hello_P{Name: name}, R: hello_R{Out0: out0}})
+}
+
+func TestQAddCallDepth(t *testing.T) {
+	q := NewQ(t).SkipEndCheck()
+	_, _, line, _ := runtime.Caller(0)
+	expectHello(q, "foo", nil)
+	if want := fmt.Sprintf("mockrt_test.go:%d", line+1); q.calls[0].site != want {
+		t.Errorf("unexpected site: want=%s got=%s", want, q.calls[0].site)
+	}
+}
+
+var helloM = M[hello_P, hello_R]("Foo.Hello")
+
+func TestM(t *testing.T) {
+	errFoo := errors.New("foo")
+	q := NewQ(t)
+	helloM.Expect(q, hello_P{Name: "foo"}, hello_R{Out0: errFoo})
+	q.AddCall(helloM.C(hello_P{Name: "bar"}, hello_R{}))
+	if r := helloM.Call(q, hello_P{Name: "foo"}); r.Out0 != errFoo {
+		t.Errorf("unexpected result for foo: %+v", r)
+	}
+	r := helloM.Forward(q, hello_P{Name: "bar"}, func() hello_R {
+		t.Error("real implementation should not be called")
+		return hello_R{}
+	})
+	if r.Out0 != nil {
+		t.Errorf("unexpected result for bar: %+v", r)
+	}
+}
+
+func TestMUnexpectedR(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft).Stub("Foo.Hello", func(P) R { return bye_R{} })
+	r := helloM.Forward(q, hello_P{Name: "foo"}, func() hello_R { return hello_R{} })
+	if r != (hello_R{}) {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	want := []string{"result of Foo.Hello has unexpected type: want mockrt3.hello_R, got mockrt3.bye_R"}
+	if d := cmp.Diff(want, ft.fatals); d != "" {
+		t.Errorf("unexpected fatals: -want +got\n%s", d)
+	}
+}
+
+func TestQLabeled(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft).NonFatal()
+	primary, cache := q.Labeled("primary"), q.Labeled("cache")
+	cache.AddCall(C{P: bye_P{}, R: bye_R{}})
+	primary.AddCall(C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	primary.Call("Foo.Hello", hello_P{Name: "foo"})
+	cache.Call("Foo.Bye", bye_P{})
+	want := []string{"unexpected call at #0 (mockrt_test.go:N): expected cache Foo.Bye, got primary Foo.Hello\nparam={Name:foo}" +
+		"\nlooks like call #0 (mockrt_test.go:N) was skipped or is missing, the call matches #1 (mockrt_test.go:N): primary Foo.Hello called before cache Foo.Bye" +
+		"\ntimeline (expected <- actual):" +
+		"\n\t> #0 cache Foo.Bye <- primary Foo.Hello (mockrt_test.go:N)" +
+		"\n\t  #1 primary Foo.Hello",
+		"unexpected call at #1 (mockrt_test.go:N): expected primary Foo.Hello, got cache Foo.Bye\nparam={}" +
+			"\nlooks like calls #0 and #1 are swapped, it matches call #0 (mockrt_test.go:N) which was skipped" +
+			"\ntimeline (expected <- actual):" +
+			"\n\t  #0 cache Foo.Bye <- skipped" +
+			"\n\t> #1 primary Foo.Hello <- cache Foo.Bye (mockrt_test.go:N)",
+	}
+	if d := cmp.Diff(want, stripAllSites(ft.errors)); d != "" {
+		t.Errorf("unexpected errors: -want +got\n%s", d)
+	}
+	if got := q.Journal()[0].Label; got != "primary" {
+		t.Errorf("unexpected label in journal: %q", got)
+	}
+}
+
+func TestQCheckpoint(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft).NonFatal()
+	q.AddCall(C{P: hello_P{Name: "setup"}, R: hello_R{}}).Mark("setup")
+	q.AddCall(C{P: hello_P{Name: "act"}, R: hello_R{}}).Mark("act")
+	q.Checkpoint("setup")
+	q.Call("Foo.Hello", hello_P{Name: "setup"})
+	q.Checkpoint("setup")
+	q.Call("Foo.Hello", hello_P{Name: "act"})
+	q.Checkpoint("setup")
+	q.Checkpoint("act")
+	q.Checkpoint("teardown")
+	want := []string{
+		"checkpoint \"setup\" (mockrt_test.go:N) is not reached, there are 1 non-proceeded calls:" +
+			"\n\t#0 Foo.Hello {Name:setup} (mockrt_test.go:N)",
+		"checkpoint \"setup\" (mockrt_test.go:N) is passed, there are 1 calls proceeded after it:" +
+			"\n\t#1 Foo.Hello {Name:act} (mockrt_test.go:N)",
+		"checkpoint \"teardown\" is not marked",
+	}
+	if d := cmp.Diff(want, stripAllSites(ft.errors)); d != "" {
+		t.Errorf("unexpected errors: -want +got\n%s", d)
+	}
+}
+
+func TestQFallback(t *testing.T) {
+	errFallback := errors.New("fallback")
+	q := NewQ(t, C{P: hello_P{Name: "foo"}, R: hello_R{}}).
+		Fallback("Foo.Hello", hello_R{Out0: errFallback}).
+		Lenient("Foo.Bye")
+	if r := helloM.Call(q, hello_P{Name: "bar"}); r.Out0 != errFallback {
+		t.Errorf("unexpected result for bar: %+v", r)
+	}
+	if r := q.Call("Foo.Bye", bye_P{}); r != nil {
+		t.Errorf("unexpected result for bye: %+v", r)
+	}
+	if r := helloM.Call(q, hello_P{Name: "foo"}); r.Out0 != nil {
+		t.Errorf("unexpected result for foo: %+v", r)
+	}
+	if n := len(q.Journal()); n != 3 {
+		t.Errorf("unexpected number of records: %d", n)
+	}
+}
+
+func TestQLenient(t *testing.T) {
+	for _, names := range [][]string{nil, {"Foo"}, {"Foo.Hello"}} {
+		ft := &fakeT{}
+		q := NewQ(ft).Lenient(names...)
+		if r := helloM.Call(q, hello_P{Name: "foo"}); r != (hello_R{}) {
+			t.Errorf("unexpected result for %q: %+v", names, r)
+		}
+		ft.end()
+		if len(ft.errors) != 0 || len(ft.fatals) != 0 {
+			t.Errorf("unexpected failures for %q: errors=%q fatals=%q", names, ft.errors, ft.fatals)
+		}
+	}
+}
+
+type get_P struct {
+	Ctx context.Context
+	Key string
+}
+
+func (get_P) P__()        {}
+func (get_P) M__() string { return "Foo.Get" }
+
+type get_R struct {
+	Out0 string
+	Out1 error
+}
+
+func (get_R) R__()        {}
+func (get_R) M__() string { return "Foo.Get" }
+
+var getM = M[get_P, get_R]("Foo.Get")
+
+func TestCWait(t *testing.T) {
+	onCancel := func(err error) R { return get_R{Out1: err} }
+	q := NewQ(t).WithOption(IgnoreContext)
+
+	q.AddCall(getM.C(get_P{Key: "delay"}, get_R{Out0: "a"}).Delay(20 * time.Millisecond))
+	start := time.Now()
+	if r := getM.Call(q, get_P{Ctx: context.Background(), Key: "delay"}); r.Out0 != "a" {
+		t.Errorf("unexpected result for delay: %+v", r)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("delay is too short: %s", d)
+	}
+
+	release := make(chan struct{})
+	q.AddCall(getM.C(get_P{Key: "block"}, get_R{Out0: "b"}).Block(release).OnCancel(onCancel))
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	if r := getM.Call(q, get_P{Ctx: context.Background(), Key: "block"}); r.Out0 != "b" {
+		t.Errorf("unexpected result for block: %+v", r)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	q.AddCall(getM.C(get_P{Key: "cancel"}, get_R{Out0: "c"}).Block(make(chan struct{})).OnCancel(onCancel))
+	if r := getM.Call(q, get_P{Ctx: ctx, Key: "cancel"}); r.Out1 != context.DeadlineExceeded {
+		t.Errorf("unexpected result for cancel: %+v", r)
+	}
+
+	q.AddCall(getM.C(get_P{Key: "canceled"}, get_R{Out0: "d"}).OnCancel(onCancel))
+	if r := getM.Call(q, get_P{Ctx: ctx, Key: "canceled"}); r.Out1 != context.DeadlineExceeded {
+		t.Errorf("unexpected result for canceled: %+v", r)
+	}
+}
+
+func TestQWait(t *testing.T) {
+	arrived := make(chan struct{})
+	q := NewQ(t,
+		C{P: hello_P{Name: "foo"}, R: hello_R{}}.Notify(arrived),
+		C{P: hello_P{Name: "bar"}, R: hello_R{}})
+	go func() {
+		q.Call("Foo.Hello", hello_P{Name: "foo"})
+		q.Call("Foo.Hello", hello_P{Name: "bar"})
+	}()
+	<-arrived
+	q.WaitFor(time.Second)
+	if n := q.Count("Foo.Hello"); n != 2 {
+		t.Errorf("unexpected count: %d", n)
+	}
+}
+
+func TestQWaitTimeout(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}})
+	q.WaitFor(10 * time.Millisecond)
+	want := []string{"timed out after 10ms, there are 1 non-proceeded calls:\n\t#0 Foo.Hello {Name:foo} (mockrt_test.go:N)"}
+	if d := cmp.Diff(want, stripAllSites(ft.fatals)); d != "" {
+		t.Errorf("unexpected fatals: -want +got\n%s", d)
+	}
+}
+
+func TestQFailOnGoroutine(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Call("Foo.Hello", hello_P{Name: "foo"})
+	}()
+	<-done
+	if len(ft.errors) != 1 || len(ft.fatals) != 0 {
+		t.Errorf("failure on other goroutine should be reported by Errorf: errors=%q fatals=%q", ft.errors, ft.fatals)
+	}
+}
+
+func TestQLate(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft)
+	ft.end()
+	if r := helloM.Call(q, hello_P{Name: "foo"}); r != (hello_R{}) {
+		t.Errorf("unexpected result for late call: %+v", r)
+	}
+	if len(ft.errors) != 0 || len(ft.fatals) != 0 {
+		t.Errorf("late call should not be reported to ended test: errors=%q fatals=%q", ft.errors, ft.fatals)
+	}
+	next := &fakeT{}
+	NewQ(next)
+	if len(next.errors) != 1 || !strings.HasPrefix(next.errors[0], "leaked goroutine: Foo.Hello {Name:foo} was called after the end of test") {
+		t.Errorf("late call should be reported to next test: %q", next.errors)
+	}
+}
+
+func TestHandleLate(t *testing.T) {
+	var lates []Late
+	HandleLate(func(l Late) { lates = append(lates, l) })
+	defer HandleLate(nil)
+	ft := &fakeT{}
+	q := NewQ(ft)
+	ft.end()
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	if len(lates) != 1 || lates[0].Name != "Foo.Hello" || !strings.Contains(lates[0].Stack, "TestHandleLate") {
+		t.Errorf("unexpected late calls: %+v", lates)
+	}
+}
+
+func TestQLoop(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: hello_P{Name: "foo"}, R: hello_R{}}, C{P: bye_P{}, R: bye_R{}}).Loop()
+	for i := 0; i < 3; i++ {
+		q.Call("Foo.Hello", hello_P{Name: "foo"})
+		q.Call("Foo.Bye", bye_P{})
+	}
+	q.Call("Foo.Hello", hello_P{Name: "foo"})
+	ft.end()
+	want := []string{"there are 1 non-proceeded calls:\n\t#1 Foo.Bye {} (mockrt_test.go:N)"}
+	if d := cmp.Diff(want, stripAllSites(ft.errors)); d != "" {
+		t.Errorf("unexpected errors: -want +got\n%s", d)
+	}
+	if len(ft.fatals) != 0 {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+}
+
+func TestQRepeatReset(t *testing.T) {
+	q := NewQ(t).Repeat(2, C{P: hello_P{Name: "foo"}, R: hello_R{}}, C{P: bye_P{}, R: bye_R{}})
+	for i := 0; i < 2; i++ {
+		q.Reset()
+		for j := 0; j < 2; j++ {
+			q.Call("Foo.Hello", hello_P{Name: "foo"})
+			q.Call("Foo.Bye", bye_P{})
+		}
+		if n := len(q.Journal()); n != 4 {
+			t.Errorf("unexpected number of records at #%d: %d", i, n)
+		}
+		q.IsEnd()
+	}
+}
+
+func BenchmarkQLoop(b *testing.B) {
+	q := NewQ(b, C{P: hello_P{Name: "foo"}, R: hello_R{}}).Loop().NoJournal()
+	for i := 0; i < b.N; i++ {
+		helloM.Call(q, hello_P{Name: "foo"})
+	}
+}
+
+type secret struct{ key string }
+
+type opt struct {
+	Name   string
+	Secret secret
+}
+
+type open_P struct {
+	Opt     opt
+	Handler func()
+}
+
+func (open_P) P__()        {}
+func (open_P) M__() string { return "Foo.Open" }
+
+func (open_P) Ignored__() []string { return []string{"Handler"} }
+
+type open_R struct{}
+
+func (open_R) R__()        {}
+func (open_R) M__() string { return "Foo.Open" }
+
+func TestQUncomparable(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft, C{P: open_P{Opt: opt{Name: "a", Secret: secret{key: "x"}}}, R: open_R{}})
+	q.Call("Foo.Open", open_P{Opt: opt{Name: "a", Secret: secret{key: "x"}}})
+	if len(ft.fatals) != 1 || !strings.HasPrefix(stripSites(ft.fatals[0]), "call for Foo.Open (#0, mockrt_test.go:N) can't be compared: mockrt3.open_P.Opt.Secret has unexported fields of mockrt3.secret, use an option like cmpopts.IgnoreUnexported(mockrt3.secret{}), cmp.AllowUnexported(mockrt3.secret{}) or cmp.Comparer with WithOption\ncmp panics: ") {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+}
+
+func TestQIgnored(t *testing.T) {
+	q := NewQ(t, C{P: open_P{Opt: opt{Name: "a"}}, R: open_R{}}).
+		WithOption(cmp.AllowUnexported(secret{}))
+	q.Call("Foo.Open", open_P{Opt: opt{Name: "a"}, Handler: func() {}})
+}
+
+type write_P struct {
+	W    io.Reader
+	Data []byte
+	At   time.Time
+	Err  error
+}
+
+func (write_P) P__()        {}
+func (write_P) M__() string { return "Foo.Write" }
+
+type write_R struct{}
+
+func (write_R) R__()        {}
+func (write_R) M__() string { return "Foo.Write" }
+
+func TestDefaults(t *testing.T) {
+	r := strings.NewReader("foo")
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	q := NewQ(t, C{P: write_P{W: r, At: at, Err: io.EOF}, R: write_R{}}).WithOption(Defaults)
+	q.Call("Foo.Write", write_P{W: r, Data: []byte{}, At: at.In(time.Local), Err: fmt.Errorf("wrapped: %w", io.EOF)})
+}
+
+func TestQWithOption(t *testing.T) {
+	ft := &fakeT{}
+	q := NewQ(ft).WithOption(IgnoreContext).WithOption(EquateEmpty)
+	if len(q.opts) != 2 {
+		t.Errorf("WithOption should append options: %d", len(q.opts))
+	}
+	q.AddCall(C{P: write_P{Data: []byte{}}, R: write_R{}}.WithOption(cmpopts.IgnoreFields(write_P{}, "Data")))
+	q.Call("Foo.Write", write_P{Data: []byte("foo")})
+	if len(ft.fatals) != 0 {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+}
+
+func TestRegisterComparer(t *testing.T) {
+	RegisterComparer(func(a, b secret) bool { return true })
+	defer comparer.Reset()
+	q := NewQ(t, C{P: open_P{Opt: opt{Secret: secret{key: "a"}}}, R: open_R{}})
+	q.Call("Foo.Open", open_P{Opt: opt{Secret: secret{key: "b"}}})
+
+	ft := &fakeT{}
+	q = NewQ(ft, C{P: open_P{Opt: opt{Secret: secret{key: "a"}}}, R: open_R{}}).
+		RegisterComparer(func(a, b secret) bool { return a.key == b.key })
+	q.Call("Foo.Open", open_P{Opt: opt{Secret: secret{key: "b"}}})
+	if len(ft.fatals) != 1 {
+		t.Errorf("comparer of Q should take precedence: %q", ft.fatals)
+	}
+	q.RegisterComparer(func(a secret) bool { return true })
+	if len(ft.fatals) != 2 || ft.fatals[1] != "comparer should be func(T, T) bool, got func(mockrt3.secret) bool" {
+		t.Errorf("unexpected fatals: %q", ft.fatals)
+	}
+}
+
+// strictT is a Reporter which panics on reports after the end of the test,
+// like testing.T.
+type strictT struct {
+	mu       sync.Mutex
+	ended    bool
+	cleanups []func()
+}
+
+func (*strictT) Helper() {}
+
+func (st *strictT) Errorf(format string, args ...interface{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.ended {
+		panic("Fail in goroutine after test has completed")
+	}
+}
+
+func (st *strictT) Fatalf(format string, args ...interface{}) {
+	st.Errorf(format, args...)
+}
+
+func (st *strictT) Cleanup(fn func()) {
+	st.cleanups = append(st.cleanups, fn)
+}
+
+func (st *strictT) end() {
+	for i := len(st.cleanups) - 1; i >= 0; i-- {
+		st.cleanups[i]()
+	}
+	st.mu.Lock()
+	st.ended = true
+	st.mu.Unlock()
+}
+
+func TestQLateRace(t *testing.T) {
+	var lates atomic.Int64
+	HandleLate(func(Late) { lates.Add(1) })
+	defer HandleLate(nil)
+	st := &strictT{}
+	q := NewQ(st).NonFatal()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				helloM.Call(q, hello_P{Name: "foo"})
+			}
+		}
+	}()
+	time.Sleep(time.Millisecond)
+	st.end()
+	for lates.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+}
+
+type watch_P struct {
+	Key string
+	Fn  func(string)
+}
+
+func (watch_P) P__()        {}
+func (watch_P) M__() string { return "Foo.Watch" }
+
+type watch_R struct{ Out0 error }
+
+func (watch_R) R__()        {}
+func (watch_R) M__() string { return "Foo.Watch" }
+
+func TestRecordingReplayUnrecordable(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "tape.json")
+	types := Types{
+		"Foo.Get":   {P: get_P{}, R: get_R{}},
+		"Foo.Watch": {P: watch_P{}, R: watch_R{}},
+	}
+	ctx := context.Background()
+	fn := func(string) {}
+
+	ft := &fakeT{}
+	q := Recording(ft, name)
+	getM.Forward(q, get_P{Ctx: ctx, Key: "a"}, func() get_R { return get_R{Out0: "x"} })
+	q.Forward("Foo.Watch", watch_P{Key: "a", Fn: fn}, func() R { return watch_R{} })
+	ft.end()
+	if len(ft.errors) != 0 {
+		t.Fatalf("failed to record: %q", ft.errors)
+	}
+
+	q = Replay(t, name, types)
+	if r := getM.Forward(q, get_P{Ctx: ctx, Key: "a"}, nil); r.Out0 != "x" {
+		t.Errorf("unexpected result of Foo.Get: %+v", r)
+	}
+	q.Forward("Foo.Watch", watch_P{Key: "a", Fn: fn}, nil)
+}
+
+func TestQLearnProviderUsesQ(t *testing.T) {
+	var q *Q
+	q = NewQ(t).Learn(func(name string, param P) R {
+		return hello_R{Out0: fmt.Errorf("#%d", q.Count(name))}
+	}).SkipEndCheck()
+	if r := helloM.Call(q, hello_P{Name: "foo"}); r.Out0 == nil || r.Out0.Error() != "#1" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
+
+func TestQLearnContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewQ(t).Learn(nil).SkipEndCheck()
+	q.Call("Foo.Get", get_P{Ctx: ctx, Key: "a"})
+	q.Call("Foo.Get", get_P{Ctx: context.Background(), Key: "b"})
+	want := "[]mockrt3.C{\n" +
+		"\t{P: get_P{Ctx: context.TODO(), Key: \"a\"}, R: get_R{}},\n" +
+		"\t{P: get_P{Ctx: context.TODO(), Key: \"b\"}, R: get_R{}},\n" +
+		"}\n"
+	if got := q.Learned(); got != want {
+		t.Errorf("unexpected learned calls:\nwant=%s\ngot=%s", want, got)
+	}
+}
@@ -0,0 +1,294 @@
+package mockrt3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Types is a registry of P and R types for methods, keyed by name of methods
+// like "Foo.Hello".  Values of P and R are used only for their types.
+// Generated mocks provide this as "{MockType}_Types".
+type Types map[string]C
+
+// Load creates Q with calls in a scenario file.
+//
+// A scenario file is a JSON array of calls. Each call has "method" for the
+// name of the method, "P" for fields of parameters and "R" for fields of
+// results.  Types of P and R are determined by types.  A field of error type
+// in R accepts a string as message of the error, or null.  Fields of
+// context.Context, func and channel types are not in scenario files, and are
+// not compared.
+//
+//	[
+//	  { "method": "Foo.Hello", "P": { "Name": "foo" }, "R": { "Out0": null } },
+//	  { "method": "Foo.Hello", "P": { "Name": "bar" }, "R": { "Out0": "no bar" } }
+//	]
+//
+// This is called by test codes.
+func Load(t Reporter, name string, types ...Types) *Q {
+	t.Helper()
+	q := NewQ(t)
+	if err := q.load(name, types...); err != nil {
+		q.t.Fatalf("failed to load scenario: %s", err)
+	}
+	return q
+}
+
+// load reads a scenario file, then adds calls in it.
+func (q *Q) load(name string, types ...Types) error {
+	q.t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	sd := &scenarioDecoder{name: filepath.Base(name), data: data, types: mergeTypes(types)}
+	calls, sites, err := sd.decode()
+	if err != nil {
+		return err
+	}
+	for i, c := range calls {
+		q.addCall(sites[i], []C{c})
+	}
+	return nil
+}
+
+func mergeTypes(types []Types) Types {
+	merged := Types{}
+	for _, tt := range types {
+		for k, v := range tt {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// scenarioDecoder decodes a scenario file with positions of errors.
+type scenarioDecoder struct {
+	name  string
+	data  []byte
+	types Types
+}
+
+// posError is an error at an offset in the scenario file.
+type posError struct {
+	off int64
+	err error
+}
+
+func (pe *posError) Error() string {
+	return pe.err.Error()
+}
+
+func (sd *scenarioDecoder) errorf(off int64, format string, args ...interface{}) error {
+	return &posError{off: off, err: fmt.Errorf(format, args...)}
+}
+
+// pos returns "file:line:col" for an offset.
+func (sd *scenarioDecoder) pos(off int64) string {
+	off = min(off, int64(len(sd.data)))
+	head := sd.data[:off]
+	line := bytes.Count(head, []byte("\n")) + 1
+	col := int(off) - bytes.LastIndexByte(head, '\n')
+	return fmt.Sprintf("%s:%d:%d", sd.name, line, col)
+}
+
+// decode decodes calls in the scenario file, and returns them with their
+// sites.
+func (sd *scenarioDecoder) decode() ([]C, []string, error) {
+	calls, sites, err := sd.decodeCalls()
+	if err != nil {
+		var pe *posError
+		if errors.As(err, &pe) {
+			return nil, nil, fmt.Errorf("%s: %w", sd.pos(pe.off), pe.err)
+		}
+		return nil, nil, fmt.Errorf("%s: %w", sd.name, err)
+	}
+	return calls, sites, nil
+}
+
+func (sd *scenarioDecoder) decodeCalls() ([]C, []string, error) {
+	var calls []C
+	var sites []string
+	dec := json.NewDecoder(bytes.NewReader(sd.data))
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, nil, offsetError(0, err)
+	}
+	for dec.More() {
+		off := sd.skipSpaces(dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, offsetError(0, err)
+		}
+		c, err := sd.decodeCall(off)
+		if err != nil {
+			return nil, nil, err
+		}
+		calls = append(calls, c)
+		sites = append(sites, sd.pos(off))
+	}
+	return calls, sites, nil
+}
+
+// decodeCall decodes a call at off.
+func (sd *scenarioDecoder) decodeCall(off int64) (C, error) {
+	fields, err := sd.fields(off)
+	if err != nil {
+		return C{}, err
+	}
+	var method string
+	var pf, rf *jsonField
+	for i, f := range fields {
+		switch f.key {
+		case "method":
+			if err := json.Unmarshal(f.raw, &method); err != nil {
+				return C{}, sd.errorf(f.valOff, "method should be a string")
+			}
+		case "P":
+			pf = &fields[i]
+		case "R":
+			rf = &fields[i]
+		default:
+			return C{}, sd.errorf(f.off, "unknown key %q in a call", f.key)
+		}
+	}
+	c, ok := sd.types[method]
+	if !ok {
+		return C{}, sd.errorf(off, "unknown method %q", method)
+	}
+	p, err := sd.decodeStruct(pf, reflect.TypeOf(c.P))
+	if err != nil {
+		return C{}, err
+	}
+	r, err := sd.decodeStruct(rf, reflect.TypeOf(c.R))
+	if err != nil {
+		return C{}, err
+	}
+	call := C{P: p.(P), R: r.(R)}
+	// unrecordable fields are not in the scenario, so they are not compared.
+	if names := unrecordedFields(reflect.TypeOf(c.P)); len(names) > 0 {
+		call = call.WithOption(cmpopts.IgnoreFields(c.P, names...))
+	}
+	return call, nil
+}
+
+// decodeStruct decodes a JSON object into a value of typ.  When f is nil or
+// null, this returns zero value of typ.
+func (sd *scenarioDecoder) decodeStruct(f *jsonField, typ reflect.Type) (interface{}, error) {
+	v := reflect.New(typ).Elem()
+	if f == nil || string(f.raw) == "null" {
+		return v.Interface(), nil
+	}
+	fields, err := sd.fields(f.valOff)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		sf, ok := typ.FieldByName(f.key)
+		if !ok || !sf.IsExported() {
+			return nil, sd.errorf(f.off, "unknown field %q in %s", f.key, typ.Name())
+		}
+		if err := decodeField(v.FieldByIndex(sf.Index), f.raw); err != nil {
+			return nil, sd.errorf(f.valOff, "field %s in %s: %s", f.key, typ.Name(), err)
+		}
+	}
+	return v.Interface(), nil
+}
+
+// jsonField is a field of JSON object.
+type jsonField struct {
+	key    string
+	off    int64
+	valOff int64
+	raw    json.RawMessage
+}
+
+// fields decodes a JSON object at off into fields.
+func (sd *scenarioDecoder) fields(off int64) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(sd.data[off:]))
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, offsetError(off, err)
+	}
+	var fields []jsonField
+	for dec.More() {
+		keyOff := sd.skipSpaces(off + dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, offsetError(off, err)
+		}
+		key, _ := tok.(string)
+		valOff := sd.skipSpaces(off + dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, offsetError(off, err)
+		}
+		fields = append(fields, jsonField{key: key, off: keyOff, valOff: valOff, raw: raw})
+	}
+	return fields, nil
+}
+
+// decodeField decodes a JSON value into a field.
+func decodeField(v reflect.Value, raw json.RawMessage) error {
+	if v.Type() == errorType {
+		var s *string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return errors.New("needs a string or null for error")
+		}
+		if s != nil {
+			v.Set(reflect.ValueOf(errors.New(*s)))
+		}
+		return nil
+	}
+	err := json.Unmarshal(raw, v.Addr().Interface())
+	if err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return fmt.Errorf("cannot use %s as %s", te.Value, v.Type())
+		}
+		return err
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q but got %v", want, tok)
+	}
+	return nil
+}
+
+// skipSpaces returns an offset of the next token from off.
+func (sd *scenarioDecoder) skipSpaces(off int64) int64 {
+	for off < int64(len(sd.data)) {
+		switch sd.data[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+			continue
+		}
+		break
+	}
+	return off
+}
+
+// offsetError converts errors of encoding/json to posError.
+func offsetError(base int64, err error) error {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return &posError{off: base + se.Offset, err: err}
+	}
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		return &posError{off: base + te.Offset, err: err}
+	}
+	return &posError{off: base, err: err}
+}
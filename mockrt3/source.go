@@ -0,0 +1,153 @@
+package mockrt3
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// sourcer formats values as Go source code of literals.
+type sourcer struct {
+	// pkgPath is path of a package, where types are written without package
+	// name.
+	pkgPath string
+}
+
+// typeName returns name of a type in Go source.
+func (s sourcer) typeName(typ reflect.Type) string {
+	if typ.Name() != "" && typ.PkgPath() == s.pkgPath {
+		return typ.Name()
+	}
+	return typ.String()
+}
+
+// literal returns Go source of a value.
+func (s sourcer) literal(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	typ := v.Type()
+	switch typ.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		// contexts may be pointers to unexported types like cancelCtx.
+		if typ.Implements(contextType) {
+			return "context.TODO()"
+		}
+		return s.literal(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "nil"
+		}
+		if typ.Implements(errorType) {
+			return s.errorLiteral(v)
+		}
+		if typ.Implements(contextType) {
+			return "context.TODO()"
+		}
+		if typ.Elem().Kind() == reflect.Struct {
+			return "&" + s.literal(v.Elem())
+		}
+		return fmt.Sprintf("%#v", v.Interface())
+	case reflect.Struct:
+		if typ.Implements(errorType) {
+			return s.errorLiteral(v)
+		}
+		if typ.Implements(contextType) {
+			return "context.TODO()"
+		}
+		b := &strings.Builder{}
+		b.WriteString(s.typeName(typ))
+		b.WriteString("{")
+		n := 0
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if !f.IsExported() || v.Field(i).IsZero() {
+				continue
+			}
+			if n > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "%s: %s", f.Name, s.literal(v.Field(i)))
+			n++
+		}
+		b.WriteString("}")
+		return b.String()
+	case reflect.Slice, reflect.Array:
+		if typ.Kind() == reflect.Slice && v.IsNil() {
+			return "nil"
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = s.literal(v.Index(i))
+		}
+		return s.typeName(typ) + "{" + strings.Join(elems, ", ") + "}"
+	case reflect.Map:
+		if v.IsNil() {
+			return "nil"
+		}
+		elems := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elems = append(elems, s.literal(iter.Key())+": "+s.literal(iter.Value()))
+		}
+		sort.Strings(elems)
+		return s.typeName(typ) + "{" + strings.Join(elems, ", ") + "}"
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if v.IsNil() {
+			return "nil"
+		}
+		return fmt.Sprintf("nil /* %s */", typ)
+	case reflect.String:
+		return s.convert(typ, strconv.Quote(v.String()))
+	default:
+		return s.convert(typ, fmt.Sprintf("%v", v.Interface()))
+	}
+}
+
+// convert wraps a literal of basic type with conversion to typ, when typ is a
+// named type.
+func (s sourcer) convert(typ reflect.Type, lit string) string {
+	if typ.Name() == typ.Kind().String() && typ.PkgPath() == "" {
+		return lit
+	}
+	return s.typeName(typ) + "(" + lit + ")"
+}
+
+// errorLiteral returns Go source which creates an error, which has same
+// message with v.
+func (s sourcer) errorLiteral(v reflect.Value) string {
+	err := v.Interface().(error)
+	return fmt.Sprintf("errors.New(%q)", err.Error())
+}
+
+// zeroR returns Go source of zero value of R for a P.  This depends on
+// naming convention of generated types, "FooBar_P" and "FooBar_R".
+func (s sourcer) zeroR(p P) string {
+	typ := reflect.TypeOf(p)
+	base, ok := strings.CutSuffix(typ.Name(), "_P")
+	if !ok {
+		return "nil"
+	}
+	return base + "_R{}"
+}
+
+// literals returns Go source of P and R of a record.  When R is nil, this
+// returns zero value of R for P.
+func (rec *Record) literals() (p, r string) {
+	s := sourcer{pkgPath: reflect.TypeOf(rec.P).PkgPath()}
+	if rec.R == nil {
+		return s.literal(reflect.ValueOf(rec.P)), s.zeroR(rec.P)
+	}
+	return s.literal(reflect.ValueOf(rec.P)), s.literal(reflect.ValueOf(rec.R))
+}
@@ -0,0 +1,81 @@
+package mockrt3
+
+// Forward is called instead of Call by mocks which wrap real implementations.
+// The argument fn calls the real implementation, and returns its results.
+//
+// By default, Forward works as a spy.  It forwards a call to fn, except when
+// the call matches with the next call in Q, or a stub for the method is
+// given by Stub.  All calls are recorded in the journal.
+//
+// In strict mode, Forward checks call parameter and returns result like Call.
+// In learn mode without Provider, Forward always forwards calls to fn.
+//
+// fn is nil when the mock has no real implementation.  Then a call which
+// should be forwarded fails, instead of a panic by nil.
+// This is called by mock code which wraps a real implementation.
+func (q *Q) Forward(name string, param P, fn func() R) R {
+	if q.late(name, param) {
+		return nil
+	}
+	q.t.Helper()
+	rec := q.record(name, param, 2)
+	return q.finish(rec, q.forward(name, param, fn))
+}
+
+func (q *Q) forward(name string, param P, fn func() R) R {
+	q.t.Helper()
+	switch {
+	case q.learn:
+		if q.provider != nil {
+			return q.provider(name, param)
+		}
+		return q.forwardTo(name, fn)
+	case q.strict:
+		return q.call(name, param)
+	}
+	// check and take the next call with one lock, not to let other
+	// goroutines take it between them.
+	q.mu.Lock()
+	if q.index < len(q.calls) && q.match(q.calls[q.index], name, param) {
+		c := q.takeLocked(name, param)
+		q.mu.Unlock()
+		return c.result(param)
+	}
+	q.mu.Unlock()
+	if stub, ok := q.stubs[name]; ok {
+		return stub(param)
+	}
+	return q.forwardTo(name, fn)
+}
+
+// forwardTo forwards a call to fn.  This reports a failure when fn is nil.
+func (q *Q) forwardTo(name string, fn func() R) R {
+	if fn == nil {
+		q.t.Helper()
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.fail("call for %s can't be forwarded: Real of the mock is nil, set it or expect the call", qualify(q.label, name))
+		return nil
+	}
+	return fn()
+}
+
+// Stub overrides results of a method, for mocks which wrap real
+// implementations.  Calls for the method are not forwarded to the real
+// implementation, but fn provides results for them.
+// This is called by test codes.
+func (q *Q) Stub(name string, fn func(param P) R) *Q {
+	if q.stubs == nil {
+		q.stubs = map[string]func(P) R{}
+	}
+	q.stubs[name] = fn
+	return q
+}
+
+// Strict makes mocks which wrap real implementations not to forward calls to
+// them, but to check calls like other mocks.
+// This is called by test codes.
+func (q *Q) Strict() *Q {
+	q.strict = true
+	return q
+}
@@ -0,0 +1,143 @@
+package mockrt3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Recording creates Q which records calls to a scenario file, at the end of
+// the test.  Q is in learn mode, so mocks which wrap real implementations
+// forward calls to them.  The scenario file can be replayed with Replay or
+// Load.
+// This is called by test codes.
+func Recording(t Reporter, name string) *Q {
+	t.Helper()
+	q := NewQ(t)
+	q.learn = true
+	q.tape = name
+	return q
+}
+
+// Replay creates Q with calls which were recorded in a scenario file by
+// Recording.  Mocks which wrap real implementations don't forward calls, but
+// return recorded results.  This is same with Load.
+// This is called by test codes.
+func Replay(t Reporter, name string, types ...Types) *Q {
+	t.Helper()
+	q := NewQ(t).Strict()
+	if err := q.load(name, types...); err != nil {
+		q.t.Fatalf("failed to replay: %s", err)
+	}
+	return q
+}
+
+// Tape creates Q with Recording when -mockrt3.update flag is given to the test,
+// otherwise with Replay.
+// This is called by test codes.
+func Tape(t Reporter, name string, types ...Types) *Q {
+	t.Helper()
+	if *update {
+		return Recording(t, name)
+	}
+	return Replay(t, name, types...)
+}
+
+// writeTape writes recorded calls to a scenario file.
+func (q *Q) writeTape() {
+	q.t.Helper()
+	if q.tape == "" {
+		return
+	}
+	b := &bytes.Buffer{}
+	b.WriteString("[\n")
+	for i, rec := range q.journal {
+		p, err := encodeStruct(rec.P)
+		if err != nil {
+			q.t.Errorf("failed to encode P of call #%d: %s", i, err)
+			return
+		}
+		r, err := encodeStruct(rec.R)
+		if err != nil {
+			q.t.Errorf("failed to encode R of call #%d: %s", i, err)
+			return
+		}
+		name, _ := json.Marshal(rec.Name)
+		fmt.Fprintf(b, "  {\"method\": %s, \"P\": %s, \"R\": %s}", name, p, r)
+		if i < len(q.journal)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	err := os.MkdirAll(filepath.Dir(q.tape), 0777)
+	if err == nil {
+		err = os.WriteFile(q.tape, b.Bytes(), 0666)
+	}
+	if err != nil {
+		q.t.Errorf("failed to write recorded calls: %s", err)
+	}
+}
+
+// unrecordable checks a field can't be recorded in a scenario file.  Such
+// fields are context.Context, funcs and channels.
+func unrecordable(f reflect.StructField) bool {
+	switch f.Type.Kind() {
+	case reflect.Func, reflect.Chan:
+		return true
+	}
+	return f.Type == contextType
+}
+
+// unrecordedFields returns names of exported fields of typ, which can't be
+// recorded in a scenario file.
+func unrecordedFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.IsExported() && unrecordable(f) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// encodeStruct encodes a struct to JSON object for a scenario file.  Fields
+// of error are encoded as their messages, and unrecordable fields like
+// context.Context are omitted.
+func encodeStruct(x interface{}) ([]byte, error) {
+	if x == nil {
+		return []byte("null"), nil
+	}
+	v := reflect.ValueOf(x)
+	typ := v.Type()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("not a struct: %s", typ)
+	}
+	b := &bytes.Buffer{}
+	b.WriteString("{")
+	n := 0
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || unrecordable(f) {
+			continue
+		}
+		fv := v.Field(i).Interface()
+		if f.Type == errorType && fv != nil {
+			fv = fv.(error).Error()
+		}
+		data, err := json.Marshal(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%q: %s", f.Name, data)
+		n++
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
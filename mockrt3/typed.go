@@ -0,0 +1,84 @@
+package mockrt3
+
+import "github.com/koron/mockgo/internal/caller"
+
+// M is a typed handle of a method, which binds the method's name with its
+// types of parameters (PT) and results (RT).  Calls through M are checked
+// pairing of P and R at compile time, and never panic by type assertion of R.
+//
+//	var FooHello_M = mockrt3.M[FooHello_P, FooHello_R]("Foo.Hello")
+//
+// PT and RT still need P__ and R__ markers, because calls through M are stored
+// as C with calls by untyped API like AddCall.
+type M[PT P, RT R] string
+
+// Name returns name of the method, like "Foo.Hello".
+func (m M[PT, RT]) Name() string {
+	return string(m)
+}
+
+// C creates a call of the method with typed parameters and results.
+// This is called by test codes.
+func (m M[PT, RT]) C(p PT, r RT) C {
+	return C{P: p, R: r}
+}
+
+// Expect adds a call of the method with typed parameters and results to q.
+// This is called by test codes.
+func (m M[PT, RT]) Expect(q *Q, p PT, r RT) *Q {
+	q.t.Helper()
+	q.addCall(caller.Site(1), []C{{P: p, R: r}})
+	return q
+}
+
+// Call checks call parameter and returns typed result, like Q.Call.  This
+// returns zero value of RT on failures.
+// This is called by mock code.
+func (m M[PT, RT]) Call(q *Q, p PT) RT {
+	if q.late(string(m), p) {
+		var zero RT
+		return zero
+	}
+	q.t.Helper()
+	rec := q.record(string(m), p, 2)
+	return m.result(q, p, q.finish(rec, q.call(string(m), p)))
+}
+
+// Forward is typed version of Q.Forward.
+// This is called by mock code which wraps a real implementation.
+func (m M[PT, RT]) Forward(q *Q, p PT, fn func() RT) RT {
+	if q.late(string(m), p) {
+		var zero RT
+		return zero
+	}
+	q.t.Helper()
+	rec := q.record(string(m), p, 2)
+	var f func() R
+	if fn != nil {
+		f = func() R { return fn() }
+	}
+	return m.result(q, p, q.finish(rec, q.forward(string(m), p, f)))
+}
+
+// result asserts type of r to RT.  This reports a failure instead of panic,
+// when r is not RT.  It may happen for calls which were added to Q by
+// untyped API, like AddCall or Stub.
+func (m M[PT, RT]) result(q *Q, p PT, r R) RT {
+	q.t.Helper()
+	var zero RT
+	if r == nil {
+		return zero
+	}
+	v, ok := r.(RT)
+	if !ok {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.closed.Load() {
+			q.addLate(string(m), p)
+			return zero
+		}
+		q.fail("result of %s has unexpected type: want %T, got %T", m, zero, r)
+		return zero
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package mockrt3
+
+import (
+	"context"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// extra describes optional behaviors of a call.
+type extra struct {
+	delay   time.Duration
+	jitter  time.Duration
+	release <-chan struct{}
+	cancel  func(err error) R
+	notify  chan<- struct{}
+
+	notified *sync.Once
+
+	opts []cmp.Option
+}
+
+// with returns a copy of c with modified extra.
+func (c C) with(fn func(x *extra)) C {
+	x := &extra{}
+	if c.extra != nil {
+		*x = *c.extra
+	}
+	fn(x)
+	c.extra = x
+	return c
+}
+
+// Delay makes the call to take d before it returns results.
+// This is called by test codes.
+func (c C) Delay(d time.Duration) C {
+	return c.with(func(x *extra) { x.delay, x.jitter = d, 0 })
+}
+
+// RandomDelay makes the call to take random duration between lo and hi
+// before it returns results.
+// This is called by test codes.
+func (c C) RandomDelay(lo, hi time.Duration) C {
+	return c.with(func(x *extra) { x.delay, x.jitter = lo, hi-lo })
+}
+
+// Block makes the call to block until ch is closed, then it returns results.
+// This is called by test codes.
+func (c C) Block(ch <-chan struct{}) C {
+	return c.with(func(x *extra) { x.release = ch })
+}
+
+// OnCancel makes the call to return results which are built by fn, when a
+// context.Context in parameters of the call is done before or while the call
+// waits. The argument err of fn is ctx.Err().
+//
+// Waits by Delay, RandomDelay and Block are aborted by a context.Context in
+// parameters of the call, even without OnCancel.
+// This is called by test codes.
+//
+//	C{P: FooGet_P{Key: "a"}, R: FooGet_R{Out0: "x"}}.
+//		Block(release).
+//		OnCancel(func(err error) R { return FooGet_R{Out1: err} })
+func (c C) OnCancel(fn func(err error) R) C {
+	return c.with(func(x *extra) { x.cancel = fn })
+}
+
+// result waits as described by c for a call with param, then returns results
+// of c.
+func (c C) result(param P) R {
+	x := c.extra
+	if x == nil {
+		return c.R
+	}
+	var done <-chan struct{}
+	ctx := contextOf(param)
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	var timeout <-chan time.Time
+	if d := x.delay + randDuration(x.jitter); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return c.canceled(ctx.Err())
+	}
+	// wait until both of release and timeout are passed.
+	for release := x.release; release != nil || timeout != nil; {
+		select {
+		case <-release:
+			release = nil
+		case <-timeout:
+			timeout = nil
+		case <-done:
+			return c.canceled(ctx.Err())
+		}
+	}
+	return c.R
+}
+
+// canceled returns results of c for a call whose context was done.
+func (c C) canceled(err error) R {
+	if c.extra.cancel != nil {
+		return c.extra.cancel(err)
+	}
+	return c.R
+}
+
+// randDuration returns random duration in [0, d].
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+// contextOf finds a non-nil context.Context in fields of param.
+func contextOf(param P) context.Context {
+	rv := reflect.ValueOf(param)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if !rv.Type().Field(i).IsExported() || !f.Type().Implements(contextType) {
+			continue
+		}
+		if f.Kind() == reflect.Interface && f.IsNil() {
+			continue
+		}
+		if ctx, ok := f.Interface().(context.Context); ok {
+			return ctx
+		}
+	}
+	return nil
+}
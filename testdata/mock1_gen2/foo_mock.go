@@ -17,6 +17,9 @@ type FooHello_P struct {
 	Name string
 }
 
+// M__ tells mockrt the name of the method
+func (FooHello_P) M__() string { return "Foo.Hello" }
+
 // FooHello_R packs output parameters of pkg1.Foo#Hello method.
 type FooHello_R struct {
 	Out0 error
@@ -25,6 +28,6 @@ type FooHello_R struct {
 // Hello is mock of pkg1.Foo#Hello method.
 func (_m *Foo) Hello(name string) error {
 	_m.Q.T().Helper()
-	_r := (_m.Q.Call("Foo.Hello", FooHello_P{name})).(FooHello_R)
+	_r, _ := (_m.Q.Call("Foo.Hello", FooHello_P{name})).(FooHello_R)
 	return _r.Out0
 }
@@ -12,6 +12,11 @@ type Foo struct {
 	Q *mockrt3.Q
 }
 
+// Foo_Types is a registry of P and R types of Foo for mockrt3.Load.
+var Foo_Types = mockrt3.Types{
+	"Foo.Hello": {P: FooHello_P{}, R: FooHello_R{}},
+}
+
 // FooHello_P packs input parameters of pkg1.Foo#Hello method.
 type FooHello_P struct {
 	Name string
@@ -20,6 +25,15 @@ type FooHello_P struct {
 // P__ implements mockrt3.P interface
 func (FooHello_P) P__() {}
 
+// M__ tells mockrt3 the name of the method
+func (FooHello_P) M__() string { return "Foo.Hello" }
+
+// Eq__ compares parameters with == where it is possible, for mockrt3
+func (_p FooHello_P) Eq__(_x mockrt3.P) bool {
+	_o, ok := _x.(FooHello_P)
+	return ok && _p.Name == _o.Name
+}
+
 // FooHello_R packs output parameters of pkg1.Foo#Hello method.
 type FooHello_R struct {
 	Out0 error
@@ -28,9 +42,33 @@ type FooHello_R struct {
 // R__ implements mockrt3.R interface
 func (FooHello_R) R__() {}
 
+// M__ tells mockrt3 the name of the method
+func (FooHello_R) M__() string { return "Foo.Hello" }
+
+// FooHello_M is a typed handle of pkg1.Foo#Hello method.
+var FooHello_M = mockrt3.M[FooHello_P, FooHello_R]("Foo.Hello")
+
+// FooHello_E builds an expectation of pkg1.Foo#Hello method.
+type FooHello_E struct {
+	q *mockrt3.Q
+	p FooHello_P
+}
+
+// ExpectHello starts to build an expectation of pkg1.Foo#Hello method.
+// Call Return to add it to Q.
+func (_m *Foo) ExpectHello(name string) *FooHello_E {
+	return &FooHello_E{q: _m.Q, p: FooHello_P{name}}
+}
+
+// Return adds the expectation with results to Q.
+func (_e *FooHello_E) Return(Out0 error) {
+	_e.q.T().Helper()
+	_e.q.AddCallDepth(1, FooHello_M.C(_e.p, FooHello_R{Out0}))
+}
+
 // Hello is mock of pkg1.Foo#Hello method.
 func (_m *Foo) Hello(name string) error {
 	_m.Q.T().Helper()
-	_r := (_m.Q.Call("Foo.Hello", FooHello_P{name})).(FooHello_R)
+	_r := FooHello_M.Call(_m.Q, FooHello_P{name})
 	return _r.Out0
 }
@@ -0,0 +1,19 @@
+// Code generated by github.com/koron/mockgo; DO NOT EDIT.
+
+//go:build mock
+// +build mock
+
+package mock1_gen4
+
+// Foo is a mock of pkg1.Foo for test.
+type Foo struct {
+	HelloFunc func(string) error
+}
+
+// Hello is mock of pkg1.Foo#Hello method.
+func (_m *Foo) Hello(name string) error {
+	if _m.HelloFunc == nil {
+		panic("Foo.HelloFunc is nil, set it to mock pkg1.Foo#Hello method")
+	}
+	return _m.HelloFunc(name)
+}
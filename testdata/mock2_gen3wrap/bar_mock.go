@@ -0,0 +1,291 @@
+// Code generated by github.com/koron/mockgo; DO NOT EDIT.
+
+//go:build mock
+// +build mock
+
+package mock2_gen3wrap
+
+import (
+	"context"
+
+	"github.com/koron/mockgo/mockrt3"
+)
+
+// Bar is a mock of pkg2.Bar for test.
+type Bar struct {
+	Q    *mockrt3.Q
+	Real Bar_Real
+}
+
+// Bar_Real is an interface of pkg2.Bar, which Bar forwards calls to.
+type Bar_Real interface {
+	Get(key string) (string, error)
+	Put(ctx context.Context, key string, vals ...int)
+	Close()
+	Watch(key string, fn func(string)) error
+}
+
+// Bar_Types is a registry of P and R types of Bar for mockrt3.Load.
+var Bar_Types = mockrt3.Types{
+	"Bar.Get":   {P: BarGet_P{}, R: BarGet_R{}},
+	"Bar.Put":   {P: BarPut_P{}, R: BarPut_R{}},
+	"Bar.Close": {P: BarClose_P{}, R: BarClose_R{}},
+	"Bar.Watch": {P: BarWatch_P{}, R: BarWatch_R{}},
+}
+
+// BarGet_P packs input parameters of pkg2.Bar#Get method.
+type BarGet_P struct {
+	Key string
+}
+
+// P__ implements mockrt3.P interface
+func (BarGet_P) P__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarGet_P) M__() string { return "Bar.Get" }
+
+// Eq__ compares parameters with == where it is possible, for mockrt3
+func (_p BarGet_P) Eq__(_x mockrt3.P) bool {
+	_o, ok := _x.(BarGet_P)
+	return ok && _p.Key == _o.Key
+}
+
+// BarGet_R packs output parameters of pkg2.Bar#Get method.
+type BarGet_R struct {
+	Out0 string
+	Out1 error
+}
+
+// R__ implements mockrt3.R interface
+func (BarGet_R) R__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarGet_R) M__() string { return "Bar.Get" }
+
+// BarGet_M is a typed handle of pkg2.Bar#Get method.
+var BarGet_M = mockrt3.M[BarGet_P, BarGet_R]("Bar.Get")
+
+// BarGet_E builds an expectation of pkg2.Bar#Get method.
+type BarGet_E struct {
+	q *mockrt3.Q
+	p BarGet_P
+}
+
+// ExpectGet starts to build an expectation of pkg2.Bar#Get method.
+// Call Return to add it to Q.
+func (_m *Bar) ExpectGet(key string) *BarGet_E {
+	return &BarGet_E{q: _m.Q, p: BarGet_P{key}}
+}
+
+// Return adds the expectation with results to Q.
+func (_e *BarGet_E) Return(Out0 string, Out1 error) {
+	_e.q.T().Helper()
+	_e.q.AddCallDepth(1, BarGet_M.C(_e.p, BarGet_R{Out0, Out1}))
+}
+
+// Get is mock of pkg2.Bar#Get method.
+func (_m *Bar) Get(key string) (string, error) {
+	_m.Q.T().Helper()
+	var _fn func() BarGet_R
+	if _m.Real != nil {
+		_fn = func() (_rr BarGet_R) {
+			_rr.Out0, _rr.Out1 = _m.Real.Get(key)
+			return _rr
+		}
+	}
+	_r := BarGet_M.Forward(_m.Q, BarGet_P{key}, _fn)
+	return _r.Out0, _r.Out1
+}
+
+// BarPut_P packs input parameters of pkg2.Bar#Put method.
+type BarPut_P struct {
+	Ctx  context.Context
+	Key  string
+	Vals []int
+}
+
+// P__ implements mockrt3.P interface
+func (BarPut_P) P__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarPut_P) M__() string { return "Bar.Put" }
+
+// Eq__ compares parameters with == where it is possible, for mockrt3
+func (_p BarPut_P) Eq__(_x mockrt3.P) bool {
+	_o, ok := _x.(BarPut_P)
+	return ok && _p.Key == _o.Key && mockrt3.EqualSlice(_p.Vals, _o.Vals) && mockrt3.Equal(_p.Ctx, _o.Ctx)
+}
+
+// BarPut_R packs output parameters of pkg2.Bar#Put method.
+type BarPut_R struct {
+}
+
+// R__ implements mockrt3.R interface
+func (BarPut_R) R__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarPut_R) M__() string { return "Bar.Put" }
+
+// BarPut_M is a typed handle of pkg2.Bar#Put method.
+var BarPut_M = mockrt3.M[BarPut_P, BarPut_R]("Bar.Put")
+
+// BarPut_E builds an expectation of pkg2.Bar#Put method.
+type BarPut_E struct {
+	q *mockrt3.Q
+	p BarPut_P
+}
+
+// ExpectPut starts to build an expectation of pkg2.Bar#Put method.
+// Call Return to add it to Q.
+func (_m *Bar) ExpectPut(ctx context.Context, key string, vals ...int) *BarPut_E {
+	return &BarPut_E{q: _m.Q, p: BarPut_P{ctx, key, vals}}
+}
+
+// Return adds the expectation with results to Q.
+func (_e *BarPut_E) Return() {
+	_e.q.T().Helper()
+	_e.q.AddCallDepth(1, BarPut_M.C(_e.p, BarPut_R{}))
+}
+
+// Put is mock of pkg2.Bar#Put method.
+func (_m *Bar) Put(ctx context.Context, key string, vals ...int) {
+	_m.Q.T().Helper()
+	var _fn func() BarPut_R
+	if _m.Real != nil {
+		_fn = func() (_rr BarPut_R) {
+			_m.Real.Put(ctx, key, vals...)
+			return _rr
+		}
+	}
+	BarPut_M.Forward(_m.Q, BarPut_P{ctx, key, vals}, _fn)
+}
+
+// BarClose_P packs input parameters of pkg2.Bar#Close method.
+type BarClose_P struct {
+}
+
+// P__ implements mockrt3.P interface
+func (BarClose_P) P__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarClose_P) M__() string { return "Bar.Close" }
+
+// Eq__ compares parameters with == where it is possible, for mockrt3
+func (_p BarClose_P) Eq__(_x mockrt3.P) bool {
+	_, ok := _x.(BarClose_P)
+	return ok
+}
+
+// BarClose_R packs output parameters of pkg2.Bar#Close method.
+type BarClose_R struct {
+}
+
+// R__ implements mockrt3.R interface
+func (BarClose_R) R__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarClose_R) M__() string { return "Bar.Close" }
+
+// BarClose_M is a typed handle of pkg2.Bar#Close method.
+var BarClose_M = mockrt3.M[BarClose_P, BarClose_R]("Bar.Close")
+
+// BarClose_E builds an expectation of pkg2.Bar#Close method.
+type BarClose_E struct {
+	q *mockrt3.Q
+	p BarClose_P
+}
+
+// ExpectClose starts to build an expectation of pkg2.Bar#Close method.
+// Call Return to add it to Q.
+func (_m *Bar) ExpectClose() *BarClose_E {
+	return &BarClose_E{q: _m.Q, p: BarClose_P{}}
+}
+
+// Return adds the expectation with results to Q.
+func (_e *BarClose_E) Return() {
+	_e.q.T().Helper()
+	_e.q.AddCallDepth(1, BarClose_M.C(_e.p, BarClose_R{}))
+}
+
+// Close is mock of pkg2.Bar#Close method.
+func (_m *Bar) Close() {
+	_m.Q.T().Helper()
+	var _fn func() BarClose_R
+	if _m.Real != nil {
+		_fn = func() (_rr BarClose_R) {
+			_m.Real.Close()
+			return _rr
+		}
+	}
+	BarClose_M.Forward(_m.Q, BarClose_P{}, _fn)
+}
+
+// BarWatch_P packs input parameters of pkg2.Bar#Watch method.
+type BarWatch_P struct {
+	Key string
+	Fn  func(string)
+}
+
+// P__ implements mockrt3.P interface
+func (BarWatch_P) P__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarWatch_P) M__() string { return "Bar.Watch" }
+
+// Ignored__ tells mockrt3 fields of func types, which are ignored on
+// comparison because funcs can't be compared
+func (BarWatch_P) Ignored__() []string {
+	return []string{"Fn"}
+}
+
+// Eq__ compares parameters with == where it is possible, for mockrt3
+func (_p BarWatch_P) Eq__(_x mockrt3.P) bool {
+	_o, ok := _x.(BarWatch_P)
+	return ok && _p.Key == _o.Key
+}
+
+// BarWatch_R packs output parameters of pkg2.Bar#Watch method.
+type BarWatch_R struct {
+	Out0 error
+}
+
+// R__ implements mockrt3.R interface
+func (BarWatch_R) R__() {}
+
+// M__ tells mockrt3 the name of the method
+func (BarWatch_R) M__() string { return "Bar.Watch" }
+
+// BarWatch_M is a typed handle of pkg2.Bar#Watch method.
+var BarWatch_M = mockrt3.M[BarWatch_P, BarWatch_R]("Bar.Watch")
+
+// BarWatch_E builds an expectation of pkg2.Bar#Watch method.
+type BarWatch_E struct {
+	q *mockrt3.Q
+	p BarWatch_P
+}
+
+// ExpectWatch starts to build an expectation of pkg2.Bar#Watch method.
+// Call Return to add it to Q.
+func (_m *Bar) ExpectWatch(key string, fn func(string)) *BarWatch_E {
+	return &BarWatch_E{q: _m.Q, p: BarWatch_P{key, fn}}
+}
+
+// Return adds the expectation with results to Q.
+func (_e *BarWatch_E) Return(Out0 error) {
+	_e.q.T().Helper()
+	_e.q.AddCallDepth(1, BarWatch_M.C(_e.p, BarWatch_R{Out0}))
+}
+
+// Watch is mock of pkg2.Bar#Watch method.
+func (_m *Bar) Watch(key string, fn func(string)) error {
+	_m.Q.T().Helper()
+	var _fn func() BarWatch_R
+	if _m.Real != nil {
+		_fn = func() (_rr BarWatch_R) {
+			_rr.Out0 = _m.Real.Watch(key, fn)
+			return _rr
+		}
+	}
+	_r := BarWatch_M.Forward(_m.Q, BarWatch_P{key, fn}, _fn)
+	return _r.Out0
+}
@@ -0,0 +1,59 @@
+// Code generated by github.com/koron/mockgo; DO NOT EDIT.
+
+//go:build mock
+// +build mock
+
+package mock2_gen4counter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Bar is a mock of pkg2.Bar for test.
+type Bar struct {
+	GetFunc    func(string) (string, error)
+	GetCalls   atomic.Int64
+	PutFunc    func(context.Context, string, ...int)
+	PutCalls   atomic.Int64
+	CloseFunc  func()
+	CloseCalls atomic.Int64
+	WatchFunc  func(string, func(string)) error
+	WatchCalls atomic.Int64
+}
+
+// Get is mock of pkg2.Bar#Get method.
+func (_m *Bar) Get(key string) (string, error) {
+	if _m.GetFunc == nil {
+		panic("Bar.GetFunc is nil, set it to mock pkg2.Bar#Get method")
+	}
+	_m.GetCalls.Add(1)
+	return _m.GetFunc(key)
+}
+
+// Put is mock of pkg2.Bar#Put method.
+func (_m *Bar) Put(ctx context.Context, key string, vals ...int) {
+	if _m.PutFunc == nil {
+		panic("Bar.PutFunc is nil, set it to mock pkg2.Bar#Put method")
+	}
+	_m.PutCalls.Add(1)
+	_m.PutFunc(ctx, key, vals...)
+}
+
+// Close is mock of pkg2.Bar#Close method.
+func (_m *Bar) Close() {
+	if _m.CloseFunc == nil {
+		panic("Bar.CloseFunc is nil, set it to mock pkg2.Bar#Close method")
+	}
+	_m.CloseCalls.Add(1)
+	_m.CloseFunc()
+}
+
+// Watch is mock of pkg2.Bar#Watch method.
+func (_m *Bar) Watch(key string, fn func(string)) error {
+	if _m.WatchFunc == nil {
+		panic("Bar.WatchFunc is nil, set it to mock pkg2.Bar#Watch method")
+	}
+	_m.WatchCalls.Add(1)
+	return _m.WatchFunc(key, fn)
+}
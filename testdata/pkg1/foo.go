@@ -3,6 +3,7 @@ package pkg1
 //go:generate go run ../../ -package ./ -outdir ../mock1_gen1 -revision 1 Foo
 //go:generate go run ../../ -package ./ -outdir ../mock1_gen2 -revision 2 Foo
 //go:generate go run ../../ -package ./ -outdir ../mock1_gen3 -revision 3 Foo
+//go:generate go run ../../ -package ./ -outdir ../mock1_gen4 -revision 4 Foo
 
 type Foo struct {
 }
@@ -0,0 +1,17 @@
+package pkg2
+
+import "context"
+
+//go:generate go run ../../ -package ./ -outdir ../mock2_gen3wrap -revision 3 -wrap Bar
+//go:generate go run ../../ -package ./ -outdir ../mock2_gen4counter -revision 4 -counter Bar
+
+type Bar struct {
+}
+
+func (*Bar) Get(key string) (string, error) {}
+
+func (*Bar) Put(ctx context.Context, key string, vals ...int) {}
+
+func (*Bar) Close() {}
+
+func (*Bar) Watch(key string, fn func(string)) error {}